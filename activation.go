@@ -0,0 +1,254 @@
+package neuralnet
+
+import (
+	"errors"
+	"fmt"
+	"math"
+	"strconv"
+	"strings"
+	"sync"
+
+	"github.com/codehex/neuralnet/mx"
+)
+
+type ActivationFuncName string
+
+const (
+	ActivationFuncNameReLU      ActivationFuncName = "relu"
+	ActivationFuncNameSigmoid   ActivationFuncName = "sigmoid"
+	ActivationFuncNameTanh      ActivationFuncName = "tanh"
+	ActivationFuncNameSoftmax   ActivationFuncName = "softmax"
+	ActivationFuncNameLeakyReLU ActivationFuncName = "leaky-relu"
+	ActivationFuncNameELU       ActivationFuncName = "elu"
+	ActivationFuncNameSELU      ActivationFuncName = "selu"
+	ActivationFuncNameGELU      ActivationFuncName = "gelu"
+	ActivationFuncNameSwish     ActivationFuncName = "swish"
+	ActivationFuncNameSoftplus  ActivationFuncName = "softplus"
+	ActivationFuncNameLinear    ActivationFuncName = "linear"
+)
+
+// ActivationFuncNameSiLU is another name for the swish activation function
+const ActivationFuncNameSiLU = ActivationFuncNameSwish
+
+// defaultLeakyReLUSlope is the slope of ActivationFuncNameLeakyReLU for negative inputs
+const defaultLeakyReLUSlope = 0.01
+
+// leakyReLUPrefix starts the name of leaky relu activations with a custom slope, e.g. "leaky-relu:0.2"
+const leakyReLUPrefix = string(ActivationFuncNameLeakyReLU) + ":"
+
+// SELU constants, chosen so activations keep zero mean and unit variance through the layers
+const (
+	seluLambda = 1.0507009873554805
+	seluAlpha  = 1.6732632423543772
+)
+
+// activation holds an element wise activation function and its derivative, both as functions of z
+type activation struct {
+	f, df func(float64) float64
+}
+
+var (
+	activationsMu sync.RWMutex
+	activations   = map[ActivationFuncName]activation{
+		ActivationFuncNameReLU:     {relu, reluDerivative},
+		ActivationFuncNameSigmoid:  {sigmoid, sigmoidDerivative},
+		ActivationFuncNameTanh:     {tanh, tanhDerivative},
+		ActivationFuncNameELU:      {elu, eluDerivative},
+		ActivationFuncNameSELU:     {selu, seluDerivative},
+		ActivationFuncNameGELU:     {gelu, geluDerivative},
+		ActivationFuncNameSwish:    {swish, swishDerivative},
+		ActivationFuncNameSoftplus: {softplus, sigmoid},
+		ActivationFuncNameLinear:   {linear, linearDerivative},
+	}
+)
+
+// LeakyReLUActivation returns the name of a leaky relu activation function with the given slope for negative
+// inputs. ActivationFuncNameLeakyReLU uses a slope of 0.01.
+func LeakyReLUActivation(slope float64) ActivationFuncName {
+	return ActivationFuncName(leakyReLUPrefix + strconv.FormatFloat(slope, 'g', -1, 64))
+}
+
+// RegisterActivation adds a custom element wise activation function, with its derivative, that can then be used
+// by name when adding layers. Names of existing activation functions cannot be registered again.
+func RegisterActivation(name ActivationFuncName, f, df func(float64) float64) error {
+	if name == "" {
+		return errors.New("activation function name cannot be empty")
+	}
+	if f == nil || df == nil {
+		return fmt.Errorf("activation function %s must have a function and a derivative", name)
+	}
+	if name == ActivationFuncNameSoftmax || name == ActivationFuncNameLeakyReLU || strings.HasPrefix(string(name), leakyReLUPrefix) {
+		return fmt.Errorf("activation function %s is already defined", name)
+	}
+
+	activationsMu.Lock()
+	defer activationsMu.Unlock()
+	if _, ok := activations[name]; ok {
+		return fmt.Errorf("activation function %s is already defined", name)
+	}
+	activations[name] = activation{f, df}
+	return nil
+}
+
+// lookupActivation finds the element wise activation function with the given name. Softmax isn't element wise, so
+// it is handled separately by the layers.
+func lookupActivation(name ActivationFuncName) (activation, error) {
+	if name == ActivationFuncNameLeakyReLU {
+		return leakyReLU(defaultLeakyReLUSlope), nil
+	}
+	if strings.HasPrefix(string(name), leakyReLUPrefix) {
+		slope, err := strconv.ParseFloat(strings.TrimPrefix(string(name), leakyReLUPrefix), 64)
+		if err != nil {
+			return activation{}, fmt.Errorf("invalid leaky relu slope in activation function %s", name)
+		}
+		return leakyReLU(slope), nil
+	}
+
+	activationsMu.RLock()
+	defer activationsMu.RUnlock()
+	act, ok := activations[name]
+	if !ok {
+		return activation{}, fmt.Errorf("unknown activation function '%s'", name)
+	}
+	return act, nil
+}
+
+// activate applies the activation function of the layer to Z, storing the result in A
+func (l layerDefinition) activate(A mx.Matrix, Z mx.MatrixViewable) {
+	if l.actFuncLabel == ActivationFuncNameSoftmax {
+		A.Softmax(Z)
+		return
+	}
+	A.ElemOp(Z, l.activationFunc)
+}
+
+// activationGradient calculates dZ from the gradient with respect to the activations dA of the layer
+func (l layerDefinition) activationGradient(dZ mx.Matrix, Z, A, dA mx.MatrixViewable) {
+	if l.actFuncLabel == ActivationFuncNameSoftmax {
+		dZ.SoftmaxBackward(A, dA)
+		return
+	}
+	dZ.ElemOp(Z, l.activationDerFunc)
+	dZ.MatrixElemOp(dZ, dA, func(v1, v2 float64) float64 { return v1 * v2 })
+}
+
+func (l layerDefinition) ActivationFunc() func(float64) float64 {
+	return l.activationFunc
+}
+
+func (l layerDefinition) ActivationDerivativeFunc() func(float64) float64 {
+	return l.activationDerFunc
+}
+
+func relu(z float64) float64 {
+	if z > 0 {
+		return z
+	}
+	return 0
+}
+
+func reluDerivative(z float64) float64 {
+	if z > 0 {
+		return 1
+	}
+	return 0
+}
+
+func leakyReLU(slope float64) activation {
+	return activation{
+		f: func(z float64) float64 {
+			if z > 0 {
+				return z
+			}
+			return slope * z
+		},
+		df: func(z float64) float64 {
+			if z > 0 {
+				return 1
+			}
+			return slope
+		},
+	}
+}
+
+func sigmoid(z float64) float64 {
+	return 1 / (1 + math.Exp(-z))
+}
+
+func sigmoidDerivative(z float64) float64 {
+	return sigmoid(z) * (1 - sigmoid(z))
+}
+
+func tanh(z float64) float64 {
+	return math.Tanh(z)
+}
+
+func tanhDerivative(z float64) float64 {
+	return 1 - math.Pow(tanh(z), 2)
+}
+
+func elu(z float64) float64 {
+	if z > 0 {
+		return z
+	}
+	return math.Expm1(z)
+}
+
+func eluDerivative(z float64) float64 {
+	if z > 0 {
+		return 1
+	}
+	return math.Exp(z)
+}
+
+func selu(z float64) float64 {
+	if z > 0 {
+		return seluLambda * z
+	}
+	return seluLambda * seluAlpha * math.Expm1(z)
+}
+
+func seluDerivative(z float64) float64 {
+	if z > 0 {
+		return seluLambda
+	}
+	return seluLambda * seluAlpha * math.Exp(z)
+}
+
+// gelu uses the exact form z * Φ(z), where Φ is the cumulative distribution function of the standard normal
+func gelu(z float64) float64 {
+	return z * normalCDF(z)
+}
+
+func geluDerivative(z float64) float64 {
+	return normalCDF(z) + z*math.Exp(-z*z/2)/math.Sqrt(2*math.Pi)
+}
+
+func normalCDF(z float64) float64 {
+	return (1 + math.Erf(z/math.Sqrt2)) / 2
+}
+
+func swish(z float64) float64 {
+	return z * sigmoid(z)
+}
+
+func swishDerivative(z float64) float64 {
+	s := sigmoid(z)
+	return s + z*s*(1-s)
+}
+
+// softplus is calculated so large inputs don't overflow, its derivative is the sigmoid function
+func softplus(z float64) float64 {
+	if z > 0 {
+		return z + math.Log1p(math.Exp(-z))
+	}
+	return math.Log1p(math.Exp(z))
+}
+
+func linear(z float64) float64 {
+	return z
+}
+
+func linearDerivative(z float64) float64 {
+	return 1
+}
@@ -0,0 +1,81 @@
+package neuralnet_test
+
+import (
+	"math"
+	"testing"
+
+	"github.com/codehex/neuralnet"
+)
+
+func TestActivationDerivatives(t *testing.T) {
+	names := []neuralnet.ActivationFuncName{
+		neuralnet.ActivationFuncNameReLU,
+		neuralnet.ActivationFuncNameSigmoid,
+		neuralnet.ActivationFuncNameTanh,
+		neuralnet.ActivationFuncNameLeakyReLU,
+		neuralnet.LeakyReLUActivation(0.2),
+		neuralnet.ActivationFuncNameELU,
+		neuralnet.ActivationFuncNameSELU,
+		neuralnet.ActivationFuncNameGELU,
+		neuralnet.ActivationFuncNameSwish,
+		neuralnet.ActivationFuncNameSoftplus,
+		neuralnet.ActivationFuncNameLinear,
+	}
+	for _, name := range names {
+		t.Run(string(name), func(t *testing.T) {
+			hyperParams, err := neuralnet.NewHyperParametersBuilder().
+				AddLayers(name, 2).
+				AddLayers(neuralnet.ActivationFuncNameSigmoid, 1).
+				Build()
+			if err != nil {
+				t.Fatal(err)
+			}
+			f := hyperParams.Layer(1).ActivationFunc()
+			df := hyperParams.Layer(1).ActivationDerivativeFunc()
+
+			// Compare the derivative with a numerical estimate, away from the kink at 0
+			const h = 1e-6
+			for _, z := range []float64{-3, -0.5, 0.7, 2.5} {
+				estimate := (f(z+h) - f(z-h)) / (2 * h)
+				if math.Abs(estimate-df(z)) > 1e-5 {
+					t.Errorf("Expected derivative at %v to be %v, but got %v", z, estimate, df(z))
+				}
+			}
+		})
+	}
+}
+
+func TestRegisterActivation(t *testing.T) {
+	cube := func(z float64) float64 { return z * z * z }
+	cubeDerivative := func(z float64) float64 { return 3 * z * z }
+	if err := neuralnet.RegisterActivation("cube", cube, cubeDerivative); err != nil {
+		t.Fatal(err)
+	}
+	if err := neuralnet.RegisterActivation("cube", cube, cubeDerivative); err == nil {
+		t.Errorf("Expected an error registering the same activation function twice")
+	}
+	if err := neuralnet.RegisterActivation(neuralnet.ActivationFuncNameReLU, cube, cubeDerivative); err == nil {
+		t.Errorf("Expected an error registering a built in activation function")
+	}
+
+	hyperParams, err := neuralnet.NewHyperParametersBuilder().
+		AddLayers("cube", 2).
+		AddLayers(neuralnet.ActivationFuncNameSigmoid, 1).
+		Build()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if v := hyperParams.Layer(1).ActivationFunc()(2); v != 8 {
+		t.Errorf("Expected the registered activation function to be used, but got %v", v)
+	}
+}
+
+func TestBuildUnknownActivation(t *testing.T) {
+	_, err := neuralnet.NewHyperParametersBuilder().
+		AddLayers("unknown", 2).
+		AddLayers(neuralnet.ActivationFuncNameSigmoid, 1).
+		Build()
+	if err == nil {
+		t.Errorf("Expected an error for an unknown activation function")
+	}
+}
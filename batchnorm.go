@@ -0,0 +1,83 @@
+package neuralnet
+
+import (
+	"errors"
+	"fmt"
+	"math"
+)
+
+// batchNormConfig holds the settings shared by all the batch normalized layers
+type batchNormConfig struct {
+	// momentum weighs the running mean and variance against the statistics of each new mini-batch
+	momentum float64
+	// epsilon is added to the variance to avoid dividing by zero
+	epsilon float64
+}
+
+func (c batchNormConfig) validate() error {
+	if c.momentum < 0 || c.momentum >= 1 {
+		return errors.New("batch normalization momentum must be between 0 and 1 (exclusive)")
+	}
+	if c.epsilon <= 0 {
+		return errors.New("batch normalization epsilon must be greater than 0")
+	}
+	return nil
+}
+
+func (c batchNormConfig) String() string {
+	return fmt.Sprintf("momentum %.5g, epsilon %.5g", c.momentum, c.epsilon)
+}
+
+// normalize normalizes Z of layer i, then scales it by gamma and shifts it by beta (the biases of the layer). While
+// training, Z is normalized with the mean and variance of the mini-batch, which are added to the running statistics,
+// and the running statistics are used otherwise.
+func (c batchNormConfig) normalize(layer cacheLayer, params *parameters, i int, training bool) {
+	rows, columns := layer.Z.Dims()
+	for r := 0; r < rows; r++ {
+		mean, variance := params.mean[i].At(r, 0), params.variance[i].At(r, 0)
+		if training {
+			mean, variance = 0, 0
+			for j := 0; j < columns; j++ {
+				mean += layer.Z.At(r, j)
+			}
+			mean /= float64(columns)
+			for j := 0; j < columns; j++ {
+				variance += (layer.Z.At(r, j) - mean) * (layer.Z.At(r, j) - mean)
+			}
+			variance /= float64(columns)
+			params.mean[i].Set(r, 0, c.momentum*params.mean[i].At(r, 0)+(1-c.momentum)*mean)
+			params.variance[i].Set(r, 0, c.momentum*params.variance[i].At(r, 0)+(1-c.momentum)*variance)
+		}
+
+		invStdDev := 1 / math.Sqrt(variance+c.epsilon)
+		layer.InvStdDev.Set(r, 0, invStdDev)
+		gamma, beta := params.gamma[i].At(r, 0), params.b[i].At(r, 0)
+		for j := 0; j < columns; j++ {
+			normalized := (layer.Z.At(r, j) - mean) * invStdDev
+			layer.ZNorm.Set(r, j, normalized)
+			layer.Z.Set(r, j, gamma*normalized+beta)
+		}
+	}
+}
+
+// normalizeBackward calculates the gradients of gamma and beta from DZ, the gradient with respect to the scaled and
+// shifted output, then replaces DZ with the gradient with respect to Z before it was normalized
+func (c batchNormConfig) normalizeBackward(layer cacheLayer, params *parameters, i int) {
+	layer.Db.RowSum(layer.DZ, true)
+	rows, columns := layer.DZ.Dims()
+	for r := 0; r < rows; r++ {
+		dGamma := 0.0
+		for j := 0; j < columns; j++ {
+			dGamma += layer.DZ.At(r, j) * layer.ZNorm.At(r, j)
+		}
+		dGamma /= float64(columns)
+		layer.DGamma.Set(r, 0, dGamma)
+
+		// The mean and variance of the mini-batch depend on every example, which adds the mean gradient terms
+		dBeta := layer.Db.At(r, 0)
+		scale := params.gamma[i].At(r, 0) * layer.InvStdDev.At(r, 0)
+		for j := 0; j < columns; j++ {
+			layer.DZ.Set(r, j, scale*(layer.DZ.At(r, j)-dBeta-layer.ZNorm.At(r, j)*dGamma))
+		}
+	}
+}
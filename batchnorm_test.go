@@ -0,0 +1,110 @@
+package neuralnet_test
+
+import (
+	"bytes"
+	"math"
+	"testing"
+
+	"github.com/codehex/neuralnet"
+)
+
+func TestTrainBatchNormModel(t *testing.T) {
+	// Points are labelled by the quadrant they are in, which isn't linearly separable
+	var features [][]float64
+	var labels []string
+	for _, x := range []float64{-1, -0.5, 0.5, 1} {
+		for _, y := range []float64{-1, -0.5, 0.5, 1} {
+			features = append(features, []float64{x, y})
+			if x*y > 0 {
+				labels = append(labels, "same")
+			} else {
+				labels = append(labels, "different")
+			}
+		}
+	}
+	set, err := neuralnet.NewClassificationFeatureSet(features, labels, "different", "same")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	hyperParams, err := neuralnet.NewHyperParametersBuilder().
+		AddNBatchNormLayers(neuralnet.ActivationFuncNameReLU, 8, 3).
+		AddLayers(neuralnet.ActivationFuncNameSigmoid, 1).
+		UseAdam(0.9, 0.999, 1e-8).
+		SetLearningRate(0.05).
+		SetMiniBatchSize(8).
+		SetIterations(200).
+		SetSeed(1).
+		Build()
+	if err != nil {
+		t.Fatal(err)
+	}
+	model, err := hyperParams.TrainModel(set, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	eval, err := model.Evaluate(set)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if eval.Accuracy < 1 {
+		t.Errorf("Expected the model to separate the quadrants, but got %v", eval)
+	}
+
+	// Predictions use the running statistics, so an example gets the same prediction on its own as in a set
+	predictions, err := model.Predict(set)
+	if err != nil {
+		t.Fatal(err)
+	}
+	single, err := neuralnet.NewClassificationFeatureSet(features[:1], labels[:1], "different", "same")
+	if err != nil {
+		t.Fatal(err)
+	}
+	singlePredictions, err := model.Predict(single)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if math.Abs(singlePredictions[0].Probabilities[1]-predictions[0].Probabilities[1]) > 1e-12 {
+		t.Errorf("Expected the same probability for an example on its own, but got %v and %v",
+			singlePredictions[0].Probabilities, predictions[0].Probabilities)
+	}
+
+	var buf bytes.Buffer
+	if err := model.Save(&buf); err != nil {
+		t.Fatal(err)
+	}
+	loaded, err := neuralnet.LoadModel(&buf)
+	if err != nil {
+		t.Fatal(err)
+	}
+	loadedPredictions, err := loaded.Predict(set)
+	if err != nil {
+		t.Fatal(err)
+	}
+	for i := range predictions {
+		if loadedPredictions[i].Probabilities[1] != predictions[i].Probabilities[1] {
+			t.Fatalf("Expected the loaded model to predict %v for example %d, but got %v",
+				predictions[i].Probabilities, i, loadedPredictions[i].Probabilities)
+		}
+	}
+}
+
+func TestBuildBatchNormInvalid(t *testing.T) {
+	builders := map[string]neuralnet.HyperParametersBuilder{
+		"the last layer": neuralnet.NewHyperParametersBuilder().
+			AddBatchNormLayers(neuralnet.ActivationFuncNameSigmoid, 1),
+		"a momentum of 1": neuralnet.NewHyperParametersBuilder().
+			AddBatchNormLayers(neuralnet.ActivationFuncNameReLU, 4).
+			AddLayers(neuralnet.ActivationFuncNameSigmoid, 1).
+			SetBatchNormalization(1, 1e-5),
+		"an epsilon of 0": neuralnet.NewHyperParametersBuilder().
+			AddBatchNormLayers(neuralnet.ActivationFuncNameReLU, 4).
+			AddLayers(neuralnet.ActivationFuncNameSigmoid, 1).
+			SetBatchNormalization(0.9, 0),
+	}
+	for name, builder := range builders {
+		if _, err := builder.Build(); err == nil {
+			t.Errorf("Expected an error for batch normalization with %s", name)
+		}
+	}
+}
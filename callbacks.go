@@ -0,0 +1,121 @@
+package neuralnet
+
+import "fmt"
+
+// TrainingCallback receives events while a model is being trained. The model passed in the events shares its
+// parameters with the training, so it can be evaluated or saved but is only valid until the callback returns.
+type TrainingCallback interface {
+	OnTrainStart(event TrainStartEvent)
+	OnBatchEnd(event BatchEndEvent)
+	OnEpochEnd(event EpochEndEvent)
+	OnTrainEnd(event TrainEndEvent)
+}
+
+type TrainStartEvent struct {
+	Iterations      uint
+	BatchesPerEpoch int
+	Model           *TrainedModel
+}
+
+type BatchEndEvent struct {
+	Iteration    uint
+	Batch        int
+	Loss         float64
+	LearningRate float64
+	Model        *TrainedModel
+}
+
+type EpochEndEvent struct {
+	Iteration uint
+	Metrics   EpochMetrics
+	Model     *TrainedModel
+}
+
+type TrainEndEvent struct {
+	History *TrainingHistory
+	Model   *TrainedModel
+}
+
+// CallbackFuncs implements TrainingCallback with optional functions, so only the events of interest need to
+// be handled
+type CallbackFuncs struct {
+	TrainStart func(event TrainStartEvent)
+	BatchEnd   func(event BatchEndEvent)
+	EpochEnd   func(event EpochEndEvent)
+	TrainEnd   func(event TrainEndEvent)
+}
+
+func (c CallbackFuncs) OnTrainStart(event TrainStartEvent) {
+	if c.TrainStart != nil {
+		c.TrainStart(event)
+	}
+}
+
+func (c CallbackFuncs) OnBatchEnd(event BatchEndEvent) {
+	if c.BatchEnd != nil {
+		c.BatchEnd(event)
+	}
+}
+
+func (c CallbackFuncs) OnEpochEnd(event EpochEndEvent) {
+	if c.EpochEnd != nil {
+		c.EpochEnd(event)
+	}
+}
+
+func (c CallbackFuncs) OnTrainEnd(event TrainEndEvent) {
+	if c.TrainEnd != nil {
+		c.TrainEnd(event)
+	}
+}
+
+// EpochMetrics holds the results of a single iteration over the training set
+type EpochMetrics struct {
+	Iteration uint
+	// Loss is the cost of the training set, averaged over the mini-batches
+	Loss         float64
+	LearningRate float64
+	// Validation is the evaluation of the validation set, or nil if there isn't one
+	Validation *Evaluation
+}
+
+// TrainingHistory records the metrics of every iteration of the training
+type TrainingHistory struct {
+	Epochs []EpochMetrics
+	// StoppedEarly is set if early stopping ended the training before all the iterations ran, in which case
+	// the model has the parameters from BestIteration
+	StoppedEarly  bool
+	BestIteration uint
+	// BudgetExhausted is set if the training ended because it ran out of the time budget
+	BudgetExhausted bool
+}
+
+// consoleLogger prints the progress of the training every few iterations
+type consoleLogger struct {
+	every uint
+}
+
+func (c consoleLogger) OnTrainStart(event TrainStartEvent) {
+	fmt.Println("training for", event.Iterations, "iterations of", event.BatchesPerEpoch, "batch(es)")
+}
+
+func (c consoleLogger) OnBatchEnd(event BatchEndEvent) {
+	if event.Iteration != 0 && event.Iteration%c.every == 0 {
+		fmt.Println("iter:", event.Iteration, ", batch:", event.Batch, ", cost", event.Loss)
+	}
+}
+
+func (c consoleLogger) OnEpochEnd(event EpochEndEvent) {
+	if event.Iteration != 0 && event.Iteration%c.every == 0 && event.Metrics.Validation != nil {
+		fmt.Println("iter:", event.Iteration, ", validation", *event.Metrics.Validation)
+	}
+}
+
+func (c consoleLogger) OnTrainEnd(event TrainEndEvent) {
+	if event.History.BudgetExhausted {
+		fmt.Println("time budget exhausted after", len(event.History.Epochs), "iteration(s)")
+	}
+	if event.History.StoppedEarly {
+		fmt.Println("stopped early, best validation loss at iter", event.History.BestIteration)
+	}
+}
@@ -0,0 +1,198 @@
+package neuralnet
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"path"
+)
+
+// CheckpointFileName is the name of the file checkpoints are written to, within the checkpoint directory. Each
+// checkpoint replaces the previous one.
+const CheckpointFileName = "checkpoint.json"
+
+// checkpointFormat identifies a file as a training checkpoint
+const checkpointFormat = "neuralnet-checkpoint"
+
+// checkpointFormatVersion is the current version of the checkpoint format, see modelFormatVersion. Version 2 added
+// the seed.
+const checkpointFormatVersion = 2
+
+// checkpointConfig holds the checkpoint settings chosen on the HyperParametersBuilder. An empty directory disables
+// checkpoints.
+type checkpointConfig struct {
+	dir   string
+	every uint
+}
+
+// checkpointFile is the JSON document written to the checkpoint directory. On top of the model (in the same format
+// as TrainedModel.Save) it holds everything needed to continue the training: the number of iterations and
+// mini-batches processed, the seed of the random generators, the buffers of the optimizer, the early stopping state
+// and the history so far. It is only written between iterations.
+type checkpointFile struct {
+	Format        string             `json:"format"`
+	Version       int                `json:"version"`
+	Iteration     uint               `json:"iteration"`
+	Step          uint               `json:"step"`
+	Seed          int64              `json:"seed"`
+	Model         modelFile          `json:"model"`
+	Optimizer     optimizerStateFile `json:"optimizer"`
+	EarlyStopping *earlyStoppingFile `json:"earlyStopping,omitempty"`
+	History       []EpochMetrics     `json:"history"`
+}
+
+type optimizerStateFile struct {
+	Name  OptimizerName       `json:"name"`
+	State [][]layerValuesFile `json:"state"`
+}
+
+type earlyStoppingFile struct {
+	BestLoss      float64           `json:"bestLoss"`
+	BestIteration uint              `json:"bestIteration"`
+	SinceBest     uint              `json:"sinceBest"`
+	Best          []layerValuesFile `json:"best"`
+}
+
+// ResumeTraining continues the training of a model from a checkpoint, see ResumeTrainingContext
+func (h HyperParameters) ResumeTraining(checkpoint io.Reader, trainingDataSet, validationDataSet Dataset) (*TrainedModel, error) {
+	return h.ResumeTrainingContext(context.Background(), checkpoint, trainingDataSet, validationDataSet)
+}
+
+// ResumeTrainingContext continues the training of a model from a checkpoint written by an earlier run, restoring
+// the weights, optimizer state and iteration count. The hyperparameters must define the same layers and optimizer
+// as the ones the checkpoint was written with, and training continues until the total number of iterations is
+// reached.
+func (h HyperParameters) ResumeTrainingContext(ctx context.Context, checkpoint io.Reader, trainingDataSet, validationDataSet Dataset) (*TrainedModel, error) {
+	var file checkpointFile
+	if err := json.NewDecoder(checkpoint).Decode(&file); err != nil {
+		return nil, fmt.Errorf("error reading checkpoint: %w", err)
+	}
+	if file.Format != checkpointFormat {
+		return nil, fmt.Errorf("unknown checkpoint format '%s'", file.Format)
+	}
+	if file.Version < 1 || file.Version > checkpointFormatVersion {
+		return nil, fmt.Errorf("unsupported checkpoint format version %d, expected at most %d", file.Version, checkpointFormatVersion)
+	}
+
+	t, err := h.newTrainer(trainingDataSet, validationDataSet)
+	if err != nil {
+		return nil, err
+	}
+	if err := t.restore(file); err != nil {
+		return nil, fmt.Errorf("error restoring checkpoint: %w", err)
+	}
+	return t.run(ctx)
+}
+
+// checkpoint captures the state of the training, which must be between iterations
+func (t *trainer) checkpoint() checkpointFile {
+	file := checkpointFile{
+		Format:    checkpointFormat,
+		Version:   checkpointFormatVersion,
+		Iteration: t.iteration,
+		Step:      t.step,
+		Seed:      t.seed,
+		Model:     t.model.toFile(),
+		Optimizer: optimizerStateFile{Name: t.h.optimizer.name},
+		History:   t.history.Epochs,
+	}
+	for _, state := range t.opt.state() {
+		file.Optimizer.State = append(file.Optimizer.State, encodeParameters(state))
+	}
+	if t.stopping.best != nil {
+		file.EarlyStopping = &earlyStoppingFile{
+			BestLoss:      t.stopping.bestLoss,
+			BestIteration: t.stopping.bestIteration,
+			SinceBest:     t.stopping.sinceBest,
+			Best:          encodeParameters(t.stopping.best),
+		}
+	}
+	return file
+}
+
+// writeCheckpoint saves the checkpoint to the checkpoint directory. The checkpoint is written to a temporary file
+// first, so a crash while writing doesn't lose the previous checkpoint.
+func (t *trainer) writeCheckpoint(file checkpointFile) error {
+	dir := t.h.checkpoint.dir
+	if err := os.MkdirAll(dir, 0o755); err != nil {
+		return fmt.Errorf("error creating checkpoint directory %s: %w", dir, err)
+	}
+	tmp, err := os.CreateTemp(dir, "checkpoint-*.tmp")
+	if err != nil {
+		return fmt.Errorf("error creating checkpoint: %w", err)
+	}
+	defer os.Remove(tmp.Name())
+	if err := json.NewEncoder(tmp).Encode(file); err != nil {
+		tmp.Close()
+		return fmt.Errorf("error writing checkpoint: %w", err)
+	}
+	if err := tmp.Close(); err != nil {
+		return fmt.Errorf("error writing checkpoint: %w", err)
+	}
+	if err := os.Rename(tmp.Name(), path.Join(dir, CheckpointFileName)); err != nil {
+		return fmt.Errorf("error writing checkpoint: %w", err)
+	}
+	return nil
+}
+
+// restore replaces the freshly initialized state of the trainer with the one saved in the checkpoint
+func (t *trainer) restore(file checkpointFile) error {
+	loaded, err := modelFromFile(file.Model)
+	if err != nil {
+		return err
+	}
+	if len(loaded.hyper.layers) != len(t.h.layers) {
+		return fmt.Errorf("checkpoint has %d layers, but the hyperparameters define %d", len(loaded.hyper.layers), len(t.h.layers))
+	}
+	for i := 1; i < len(t.nodes); i++ {
+		rows, cols := loaded.params.W[i].Dims()
+		if uint(rows) != t.nodes[i] || uint(cols) != t.nodes[i-1] {
+			return fmt.Errorf("layer %d of the checkpoint has %dx%d weights, expected %dx%d", i, rows, cols, t.nodes[i], t.nodes[i-1])
+		}
+		if loaded.hyper.Layer(i).actFuncLabel != t.h.Layer(i).actFuncLabel {
+			return fmt.Errorf("layer %d of the checkpoint uses %s activation, but the hyperparameters use %s",
+				i, loaded.hyper.Layer(i).actFuncLabel, t.h.Layer(i).actFuncLabel)
+		}
+		if loaded.hyper.Layer(i).batchNorm != t.h.Layer(i).batchNorm {
+			return fmt.Errorf("layer %d of the checkpoint doesn't match the hyperparameters in using batch normalization", i)
+		}
+	}
+
+	if file.Optimizer.Name != t.h.optimizer.name {
+		return fmt.Errorf("checkpoint uses the %s optimizer, but the hyperparameters use %s", file.Optimizer.Name, t.h.optimizer.name)
+	}
+	state := t.opt.state()
+	if len(file.Optimizer.State) != len(state) {
+		return errors.New("checkpoint optimizer state doesn't match the optimizer")
+	}
+	for k, values := range file.Optimizer.State {
+		restored, err := decodeParameters(values, t.nodes)
+		if err != nil {
+			return fmt.Errorf("invalid optimizer state: %w", err)
+		}
+		*state[k] = *restored
+	}
+
+	if file.EarlyStopping != nil && t.h.earlyStopping.patience > 0 {
+		best, err := decodeParameters(file.EarlyStopping.Best, t.nodes)
+		if err != nil {
+			return fmt.Errorf("invalid early stopping state: %w", err)
+		}
+		t.stopping.best = best
+		t.stopping.bestLoss = file.EarlyStopping.BestLoss
+		t.stopping.bestIteration = file.EarlyStopping.BestIteration
+		t.stopping.sinceBest = file.EarlyStopping.SinceBest
+	}
+
+	t.model.params = loaded.params
+	t.history.Epochs = file.History
+	t.iteration = file.Iteration
+	t.step = file.Step
+	if file.Version >= 2 {
+		t.seed = file.Seed
+	}
+	return nil
+}
@@ -0,0 +1,177 @@
+package neuralnet_test
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"io"
+	"os"
+	"path"
+	"testing"
+
+	"github.com/codehex/neuralnet"
+)
+
+func TestCheckpointAndResumeTraining(t *testing.T) {
+	set := testImageSet(t)
+	dir := t.TempDir()
+	builder := neuralnet.NewHyperParametersBuilder().
+		AddLayers(neuralnet.ActivationFuncNameReLU, 4).
+		AddLayers(neuralnet.ActivationFuncNameSigmoid, 1).
+		SetIterations(6).
+		UseAdam(0.9, 0.999, 1e-8).
+		SetCheckpointing(dir, 2)
+
+	ctx, cancel := context.WithCancel(context.Background())
+	interrupted, err := builder.
+		AddCallbacks(neuralnet.CallbackFuncs{
+			EpochEnd: func(event neuralnet.EpochEndEvent) {
+				if event.Iteration == 2 {
+					cancel()
+				}
+			},
+		}).
+		Build()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, err := interrupted.TrainModelContext(ctx, set, nil); !errors.Is(err, context.Canceled) {
+		t.Fatalf("Expected a cancelled error, but got %v", err)
+	}
+
+	checkpoint, err := os.Open(path.Join(dir, neuralnet.CheckpointFileName))
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer checkpoint.Close()
+
+	var resumedIterations []uint
+	resumed, err := builder.
+		AddCallbacks(neuralnet.CallbackFuncs{
+			EpochEnd: func(event neuralnet.EpochEndEvent) {
+				resumedIterations = append(resumedIterations, event.Iteration)
+			},
+		}).
+		Build()
+	if err != nil {
+		t.Fatal(err)
+	}
+	model, err := resumed.ResumeTraining(checkpoint, set, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	// The checkpoint was written on cancellation after 3 iterations, so the remaining 3 should run
+	if len(resumedIterations) != 3 || resumedIterations[0] != 3 {
+		t.Errorf("Expected iterations 3 to 5 to run after resuming, but got %v", resumedIterations)
+	}
+	if len(model.History().Epochs) != 6 {
+		t.Errorf("Expected the history to include all 6 iterations, but got %d", len(model.History().Epochs))
+	}
+}
+
+func TestResumeTrainingMismatchedLayers(t *testing.T) {
+	set := testImageSet(t)
+	dir := t.TempDir()
+	hyperParams, err := neuralnet.NewHyperParametersBuilder().
+		AddLayers(neuralnet.ActivationFuncNameReLU, 4).
+		AddLayers(neuralnet.ActivationFuncNameSigmoid, 1).
+		SetIterations(2).
+		SetCheckpointing(dir, 1).
+		Build()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, err := hyperParams.TrainModel(set, nil); err != nil {
+		t.Fatal(err)
+	}
+
+	other, err := neuralnet.NewHyperParametersBuilder().
+		AddLayers(neuralnet.ActivationFuncNameReLU, 3).
+		AddLayers(neuralnet.ActivationFuncNameSigmoid, 1).
+		Build()
+	if err != nil {
+		t.Fatal(err)
+	}
+	checkpoint, err := os.Open(path.Join(dir, neuralnet.CheckpointFileName))
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer checkpoint.Close()
+	if _, err := other.ResumeTraining(checkpoint, set, nil); err == nil {
+		t.Errorf("Expected an error resuming with different layers")
+	}
+}
+
+func TestResumeTrainingInterruptedMidIteration(t *testing.T) {
+	set := testImageSet(t)
+	dir := t.TempDir()
+	// Without a seed, the resumed training must still shuffle and drop out like the interrupted one would have
+	builder := neuralnet.NewHyperParametersBuilder().
+		AddLayers(neuralnet.ActivationFuncNameReLU, 4).
+		AddLayers(neuralnet.ActivationFuncNameSigmoid, 1).
+		SetIterations(6).
+		SetMiniBatchSize(5).
+		SetDropoutKeepProbability(0.8).
+		UseAdam(0.9, 0.999, 1e-8).
+		SetCheckpointing(dir, 100)
+
+	ctx, cancel := context.WithCancel(context.Background())
+	interrupted, err := builder.
+		AddCallbacks(neuralnet.CallbackFuncs{
+			BatchEnd: func(event neuralnet.BatchEndEvent) {
+				if event.Iteration == 3 && event.Batch == 1 {
+					cancel()
+				}
+			},
+		}).
+		Build()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, err := interrupted.TrainModelContext(ctx, set, nil); !errors.Is(err, context.Canceled) {
+		t.Fatalf("Expected a cancelled error, but got %v", err)
+	}
+	checkpoint, err := os.Open(path.Join(dir, neuralnet.CheckpointFileName))
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer checkpoint.Close()
+	hyperParams, err := builder.Build()
+	if err != nil {
+		t.Fatal(err)
+	}
+	resumed, err := hyperParams.ResumeTraining(checkpoint, set, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	// The interrupted iteration runs again from its start, so the losses match a training of the same seed that
+	// wasn't interrupted
+	epochs := resumed.History().Epochs
+	if len(epochs) != 6 {
+		t.Fatalf("Expected the history to include all 6 iterations, but got %d", len(epochs))
+	}
+	var seed struct {
+		Seed int64 `json:"seed"`
+	}
+	if _, err := checkpoint.Seek(0, io.SeekStart); err != nil {
+		t.Fatal(err)
+	}
+	if err := json.NewDecoder(checkpoint).Decode(&seed); err != nil {
+		t.Fatal(err)
+	}
+	uninterrupted, err := builder.SetCheckpointing("", 0).SetSeed(seed.Seed).Build()
+	if err != nil {
+		t.Fatal(err)
+	}
+	model, err := uninterrupted.TrainModel(set, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	for i, epoch := range model.History().Epochs {
+		if epoch.Loss != epochs[i].Loss {
+			t.Fatalf("Expected the loss of iteration %d to be %v, but got %v", i, epoch.Loss, epochs[i].Loss)
+		}
+	}
+}
@@ -0,0 +1,267 @@
+package neuralnet
+
+import (
+	"bytes"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+	"time"
+
+	"gopkg.in/yaml.v3"
+)
+
+// hyperParametersConfig is the JSON and YAML document HyperParameters are marshalled to, e.g.
+//
+//	{
+//	  "layers": [
+//	    {"neurons": 16, "activation": "relu", "batchNorm": true},
+//	    {"neurons": 1, "activation": "sigmoid", "initializer": {"name": "xavier-uniform"}}
+//	  ],
+//	  "learningRate": 0.01,
+//	  "iterations": 1000,
+//	  "miniBatchSize": 64,
+//	  "batchNormalization": {"momentum": 0.9, "epsilon": 1e-5},
+//	  "optimizer": {"name": "momentum", "beta1": 0.9},
+//	  "schedule": {"name": "step-decay", "decayRate": 0.5, "decayInterval": 100},
+//	  "earlyStopping": {"patience": 10},
+//	  "timeBudget": "30m",
+//	  "seed": 1
+//	}
+//
+// Omitted settings take the same defaults as with NewHyperParametersBuilder. Callbacks aren't part of the document.
+type hyperParametersConfig struct {
+	Layers                 []layerConfig            `json:"layers" yaml:"layers"`
+	LearningRate           float64                  `json:"learningRate,omitempty" yaml:"learningRate,omitempty"`
+	Iterations             uint                     `json:"iterations,omitempty" yaml:"iterations,omitempty"`
+	RegularizationFactor   float64                  `json:"regularizationFactor,omitempty" yaml:"regularizationFactor,omitempty"`
+	DropoutKeepProbability float64                  `json:"dropoutKeepProbability,omitempty" yaml:"dropoutKeepProbability,omitempty"`
+	MiniBatchSize          uint                     `json:"miniBatchSize,omitempty" yaml:"miniBatchSize,omitempty"`
+	BatchNormalization     *batchNormFile           `json:"batchNormalization,omitempty" yaml:"batchNormalization,omitempty"`
+	WithoutShuffling       bool                     `json:"withoutShuffling,omitempty" yaml:"withoutShuffling,omitempty"`
+	Loss                   *lossFile                `json:"loss,omitempty" yaml:"loss,omitempty"`
+	Optimizer              *optimizerFile           `json:"optimizer,omitempty" yaml:"optimizer,omitempty"`
+	Schedule               *scheduleFile            `json:"schedule,omitempty" yaml:"schedule,omitempty"`
+	EarlyStopping          *earlyStoppingConfigFile `json:"earlyStopping,omitempty" yaml:"earlyStopping,omitempty"`
+	TimeBudget             string                   `json:"timeBudget,omitempty" yaml:"timeBudget,omitempty"`
+	Checkpoint             *checkpointConfigFile    `json:"checkpoint,omitempty" yaml:"checkpoint,omitempty"`
+	Seed                   *int64                   `json:"seed,omitempty" yaml:"seed,omitempty"`
+}
+
+type layerConfig struct {
+	Neurons     uint               `json:"neurons" yaml:"neurons"`
+	Activation  ActivationFuncName `json:"activation" yaml:"activation"`
+	Initializer *Initializer       `json:"initializer,omitempty" yaml:"initializer,omitempty"`
+	BatchNorm   bool               `json:"batchNorm,omitempty" yaml:"batchNorm,omitempty"`
+}
+
+type scheduleFile struct {
+	Name            ScheduleName `json:"name" yaml:"name"`
+	DecayRate       float64      `json:"decayRate,omitempty" yaml:"decayRate,omitempty"`
+	DecayInterval   uint         `json:"decayInterval,omitempty" yaml:"decayInterval,omitempty"`
+	MinLearningRate float64      `json:"minLearningRate,omitempty" yaml:"minLearningRate,omitempty"`
+	PerBatch        bool         `json:"perBatch,omitempty" yaml:"perBatch,omitempty"`
+	Warmup          uint         `json:"warmup,omitempty" yaml:"warmup,omitempty"`
+}
+
+type earlyStoppingConfigFile struct {
+	Patience uint    `json:"patience" yaml:"patience"`
+	MinDelta float64 `json:"minDelta,omitempty" yaml:"minDelta,omitempty"`
+}
+
+type checkpointConfigFile struct {
+	Dir   string `json:"dir" yaml:"dir"`
+	Every uint   `json:"every" yaml:"every"`
+}
+
+func (h HyperParameters) toConfig() (hyperParametersConfig, error) {
+	if h.schedule.name == ScheduleNameCustom {
+		return hyperParametersConfig{}, errors.New("custom learning rate schedule cannot be marshalled")
+	}
+	config := hyperParametersConfig{
+		LearningRate:           h.learningRate,
+		Iterations:             h.iterations,
+		RegularizationFactor:   h.regularizationFactor,
+		DropoutKeepProbability: h.keepProb,
+		MiniBatchSize:          h.miniBatchSize,
+		WithoutShuffling:       h.noShuffle,
+		Loss:                   (*lossFile)(&h.loss),
+		Optimizer: &optimizerFile{
+			Name:        h.optimizer.name,
+			Beta1:       h.optimizer.beta1,
+			Beta2:       h.optimizer.beta2,
+			Epsilon:     h.optimizer.epsilon,
+			WeightDecay: h.optimizer.weightDecay,
+		},
+		Schedule: &scheduleFile{
+			Name:            h.schedule.name,
+			DecayRate:       h.schedule.decayRate,
+			DecayInterval:   h.schedule.decayInterval,
+			MinLearningRate: h.schedule.minLearningRate,
+			PerBatch:        h.schedule.perBatch,
+			Warmup:          h.schedule.warmup,
+		},
+	}
+	for _, layer := range h.layers {
+		initializer := layer.initializer
+		config.Layers = append(config.Layers, layerConfig{
+			Neurons: layer.neurons, Activation: layer.actFuncLabel, Initializer: &initializer, BatchNorm: layer.batchNorm,
+		})
+	}
+	if h.batchNormalized() {
+		config.BatchNormalization = &batchNormFile{Momentum: h.batchNorm.momentum, Epsilon: h.batchNorm.epsilon}
+	}
+	if h.earlyStopping.patience > 0 {
+		config.EarlyStopping = &earlyStoppingConfigFile{Patience: h.earlyStopping.patience, MinDelta: h.earlyStopping.minDelta}
+	}
+	if h.timeBudget > 0 {
+		config.TimeBudget = h.timeBudget.String()
+	}
+	if h.checkpoint.dir != "" {
+		config.Checkpoint = &checkpointConfigFile{Dir: h.checkpoint.dir, Every: h.checkpoint.every}
+	}
+	if h.seeded {
+		seed := h.seed
+		config.Seed = &seed
+	}
+	return config, nil
+}
+
+// build validates the document with the same rules as HyperParametersBuilder.Build
+func (config hyperParametersConfig) build() (HyperParameters, error) {
+	builder := NewHyperParametersBuilder()
+	for _, layer := range config.Layers {
+		switch {
+		case layer.BatchNorm:
+			builder = builder.AddBatchNormLayers(layer.Activation, layer.Neurons)
+			if layer.Initializer != nil {
+				builder.params.layers[len(builder.params.layers)-1].initializer = *layer.Initializer
+			}
+		case layer.Initializer != nil:
+			builder = builder.AddLayersWithInitializer(layer.Activation, *layer.Initializer, layer.Neurons)
+		default:
+			builder = builder.AddLayers(layer.Activation, layer.Neurons)
+		}
+	}
+	if b := config.BatchNormalization; b != nil {
+		builder = builder.SetBatchNormalization(b.Momentum, b.Epsilon)
+	}
+	if config.LearningRate != 0 {
+		builder = builder.SetLearningRate(config.LearningRate)
+	}
+	if config.Iterations != 0 {
+		builder = builder.SetIterations(config.Iterations)
+	}
+	builder = builder.
+		SetRegularizationFactor(config.RegularizationFactor).
+		SetDropoutKeepProbability(config.DropoutKeepProbability).
+		SetMiniBatchSize(config.MiniBatchSize)
+	if config.WithoutShuffling {
+		builder = builder.WithoutShuffling()
+	}
+	if config.Loss != nil {
+		builder = builder.SetLoss(Loss(*config.Loss))
+	}
+	if o := config.Optimizer; o != nil {
+		builder.params.optimizer = optimizerConfig{
+			name: o.Name, beta1: o.Beta1, beta2: o.Beta2, epsilon: o.Epsilon, weightDecay: o.WeightDecay,
+		}
+	}
+	if s := config.Schedule; s != nil {
+		if s.Name == ScheduleNameCustom {
+			return HyperParameters{}, errors.New("custom learning rate schedule cannot be unmarshalled")
+		}
+		builder.params.schedule = scheduleConfig{
+			name: s.Name, decayRate: s.DecayRate, decayInterval: s.DecayInterval,
+			minLearningRate: s.MinLearningRate, perBatch: s.PerBatch, warmup: s.Warmup,
+		}
+	}
+	if e := config.EarlyStopping; e != nil {
+		builder = builder.UseEarlyStopping(e.Patience, e.MinDelta)
+	}
+	if config.TimeBudget != "" {
+		budget, err := time.ParseDuration(config.TimeBudget)
+		if err != nil {
+			return HyperParameters{}, fmt.Errorf("invalid time budget: %w", err)
+		}
+		builder = builder.SetTimeBudget(budget)
+	}
+	if c := config.Checkpoint; c != nil {
+		builder = builder.SetCheckpointing(c.Dir, c.Every)
+	}
+	if config.Seed != nil {
+		builder = builder.SetSeed(*config.Seed)
+	}
+	return builder.Build()
+}
+
+func (h HyperParameters) MarshalJSON() ([]byte, error) {
+	config, err := h.toConfig()
+	if err != nil {
+		return nil, err
+	}
+	return json.Marshal(config)
+}
+
+// UnmarshalJSON loads hyperparameters from a JSON document, validating them like HyperParametersBuilder.Build
+func (h *HyperParameters) UnmarshalJSON(data []byte) error {
+	var config hyperParametersConfig
+	decoder := json.NewDecoder(bytes.NewReader(data))
+	decoder.DisallowUnknownFields()
+	if err := decoder.Decode(&config); err != nil {
+		return err
+	}
+	params, err := config.build()
+	if err != nil {
+		return err
+	}
+	*h = params
+	return nil
+}
+
+func (h HyperParameters) MarshalYAML() (interface{}, error) {
+	return h.toConfig()
+}
+
+// UnmarshalYAML loads hyperparameters from a YAML document, validating them like HyperParametersBuilder.Build
+func (h *HyperParameters) UnmarshalYAML(value *yaml.Node) error {
+	// Decoding the node directly would ignore unknown fields, so encode it again to decode it like the JSON, rejecting
+	// them
+	data, err := yaml.Marshal(value)
+	if err != nil {
+		return err
+	}
+	var config hyperParametersConfig
+	decoder := yaml.NewDecoder(bytes.NewReader(data))
+	decoder.KnownFields(true)
+	if err := decoder.Decode(&config); err != nil {
+		return err
+	}
+	params, err := config.build()
+	if err != nil {
+		return err
+	}
+	*h = params
+	return nil
+}
+
+// LoadHyperParameters reads hyperparameters from a JSON file, or a YAML file if its extension is .yaml or .yml
+func LoadHyperParameters(path string) (HyperParameters, error) {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return HyperParameters{}, err
+	}
+	var h HyperParameters
+	switch strings.ToLower(filepath.Ext(path)) {
+	case ".yaml", ".yml":
+		err = yaml.Unmarshal(data, &h)
+	default:
+		err = json.Unmarshal(data, &h)
+	}
+	if err != nil {
+		return HyperParameters{}, fmt.Errorf("error loading hyperparameters from %s: %w", path, err)
+	}
+	return h, nil
+}
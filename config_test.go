@@ -0,0 +1,114 @@
+package neuralnet_test
+
+import (
+	"encoding/json"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/codehex/neuralnet"
+	"gopkg.in/yaml.v3"
+)
+
+func TestHyperParametersRoundTrip(t *testing.T) {
+	hyperParams, err := neuralnet.NewHyperParametersBuilder().
+		AddLayersWithInitializer(neuralnet.ActivationFuncNameReLU, neuralnet.Initializer{Name: neuralnet.InitializerNameHeUniform}, 16, 8).
+		AddBatchNormLayers(neuralnet.ActivationFuncNameTanh, 4).
+		AddLayers(neuralnet.ActivationFuncNameSoftmax, 3).
+		SetBatchNormalization(0.8, 1e-3).
+		SetLearningRate(0.05).
+		SetIterations(200).
+		SetRegularizationFactor(0.1).
+		SetDropoutKeepProbability(0.8).
+		SetMiniBatchSize(32).
+		WithoutShuffling().
+		UseGradientDescentWithMomentum(0.9).
+		UseStepDecay(0.5, 50).
+		UseLinearWarmup(5).
+		UseEarlyStopping(10, 0.001).
+		SetTimeBudget(time.Minute).
+		SetSeed(7).
+		Build()
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	data, err := json.Marshal(hyperParams)
+	if err != nil {
+		t.Fatal(err)
+	}
+	var fromJSON neuralnet.HyperParameters
+	if err := json.Unmarshal(data, &fromJSON); err != nil {
+		t.Fatal(err)
+	}
+	if fromJSON.String() != hyperParams.String() {
+		t.Errorf("Expected the JSON round trip to give\n%s\nbut got\n%s", hyperParams, fromJSON)
+	}
+
+	data, err = yaml.Marshal(hyperParams)
+	if err != nil {
+		t.Fatal(err)
+	}
+	var fromYAML neuralnet.HyperParameters
+	if err := yaml.Unmarshal(data, &fromYAML); err != nil {
+		t.Fatal(err)
+	}
+	if fromYAML.String() != hyperParams.String() {
+		t.Errorf("Expected the YAML round trip to give\n%s\nbut got\n%s", hyperParams, fromYAML)
+	}
+}
+
+func TestLoadHyperParameters(t *testing.T) {
+	dir := t.TempDir()
+	path := filepath.Join(dir, "experiment.yaml")
+	config := `
+layers:
+  - {neurons: 8, activation: tanh}
+  - {neurons: 1, activation: sigmoid}
+learningRate: 0.1
+optimizer: {name: adam, beta1: 0.9, beta2: 0.999, epsilon: 1.0e-8}
+`
+	if err := os.WriteFile(path, []byte(config), 0o644); err != nil {
+		t.Fatal(err)
+	}
+	hyperParams, err := neuralnet.LoadHyperParameters(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if s := hyperParams.String(); !strings.Contains(s, "learning rate: 0.1") || !strings.Contains(s, "iterations: 1000") {
+		t.Errorf("Expected the learning rate from the file and the default iterations, but got\n%s", s)
+	}
+
+	for name, config := range map[string]string{
+		"negative learning rate": `{"layers": [{"neurons": 1, "activation": "sigmoid"}], "learningRate": -1}`,
+		"no layers":              `{"learningRate": 0.1}`,
+		"unknown field":          `{"layers": [{"neurons": 1, "activation": "sigmoid"}], "learnRate": 0.1}`,
+		"softmax hidden layer":   `{"layers": [{"neurons": 3, "activation": "softmax"}, {"neurons": 1, "activation": "sigmoid"}]}`,
+		"unknown YAML field":     "layers: [{neurons: 1, activation: sigmoid}]\nlearnRate: 0.1",
+	} {
+		path := filepath.Join(dir, "invalid.json")
+		if strings.Contains(name, "YAML") {
+			path = filepath.Join(dir, "invalid.yml")
+		}
+		if err := os.WriteFile(path, []byte(config), 0o644); err != nil {
+			t.Fatal(err)
+		}
+		if _, err := neuralnet.LoadHyperParameters(path); err == nil {
+			t.Errorf("Expected an error loading hyperparameters with %s", name)
+		}
+	}
+}
+
+func TestUnmarshalYAMLUnknownField(t *testing.T) {
+	// Hyperparameters embedded in a larger document are decoded through UnmarshalYAML
+	var experiment struct {
+		Name            string                    `yaml:"name"`
+		HyperParameters neuralnet.HyperParameters `yaml:"hyperParameters"`
+	}
+	data := "name: typo\nhyperParameters:\n  layers: [{neurons: 1, activation: sigmoid}]\n  learningrate: 0.1\n"
+	if err := yaml.Unmarshal([]byte(data), &experiment); err == nil || !strings.Contains(err.Error(), "learningrate") {
+		t.Errorf("Expected an error for the unknown field learningrate, but got %v", err)
+	}
+}
@@ -0,0 +1,131 @@
+package neuralnet
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"math"
+	"math/rand"
+	"sort"
+	"strings"
+)
+
+// CrossValidationOptions configures how CrossValidate builds the folds and trains the models
+type CrossValidationOptions struct {
+	// Stratified gives every fold the same proportion of each class (or combination of labels)
+	Stratified bool
+	// Seed seeds the assignment of the examples to the folds
+	Seed int64
+	// Parallelism is the number of models trained at the same time, 0 or 1 trains them one after the other
+	Parallelism uint
+}
+
+// CrossValidationResult holds the evaluation of each fold and the mean and standard deviation of its metrics
+type CrossValidationResult struct {
+	// Folds holds the evaluation of each model against the fold it wasn't trained on
+	Folds                                      []Evaluation
+	Loss, Accuracy, RMSE, MAE, R2, HammingLoss MetricSummary
+}
+
+// MetricSummary is the mean and (population) standard deviation of a metric across the folds
+type MetricSummary struct {
+	Mean, StdDev float64
+}
+
+func (r CrossValidationResult) String() string {
+	return fmt.Sprintf("%d folds, loss: %.5g ± %.5g, accuracy: %.5g ± %.5g", len(r.Folds),
+		r.Loss.Mean, r.Loss.StdDev, r.Accuracy.Mean, r.Accuracy.StdDev)
+}
+
+// CrossValidate estimates how well models trained with the hyperparameters generalize, see CrossValidateContext
+func CrossValidate(h HyperParameters, set Dataset, k uint, options CrossValidationOptions) (CrossValidationResult, error) {
+	return CrossValidateContext(context.Background(), h, set, k, options)
+}
+
+// CrossValidateContext splits the set into k folds, and trains a model per fold on the other folds, evaluating it
+// against the fold it wasn't trained on. Checkpointing is disabled for the models, early stopping can't be used,
+// and callbacks are called concurrently if models are trained in parallel.
+func CrossValidateContext(ctx context.Context, h HyperParameters, set Dataset, k uint, options CrossValidationOptions) (CrossValidationResult, error) {
+	if datasetOrNil(set) == nil {
+		return CrossValidationResult{}, errors.New("set cannot be nil")
+	}
+	if k < 2 || k > set.NumberOfExamples() {
+		return CrossValidationResult{}, fmt.Errorf("number of folds must be between 2 and %d", set.NumberOfExamples())
+	}
+	if options.Stratified && set.Regression() {
+		return CrossValidationResult{}, errors.New("stratified cross-validation requires examples with class labels")
+	}
+	h.checkpoint = checkpointConfig{}
+
+	folds := assignFolds(set, k, options)
+	evaluations := make([]Evaluation, k)
+	err := runConcurrently(len(folds), options.Parallelism, func(fold int) error {
+		var training []int
+		for other := range folds {
+			if other != fold {
+				training = append(training, folds[other]...)
+			}
+		}
+		model, err := h.TrainModelContext(ctx, newSubset(set, training), nil)
+		if err != nil {
+			return fmt.Errorf("fold %d: %w", fold+1, err)
+		}
+		evaluations[fold], err = model.Evaluate(newSubset(set, folds[fold]))
+		return err
+	})
+	if err != nil {
+		return CrossValidationResult{}, err
+	}
+
+	result := CrossValidationResult{Folds: evaluations}
+	metric := func(value func(e Evaluation) float64) MetricSummary {
+		var summary MetricSummary
+		for _, e := range evaluations {
+			summary.Mean += value(e)
+		}
+		summary.Mean /= float64(len(evaluations))
+		for _, e := range evaluations {
+			summary.StdDev += (value(e) - summary.Mean) * (value(e) - summary.Mean)
+		}
+		summary.StdDev = math.Sqrt(summary.StdDev / float64(len(evaluations)))
+		return summary
+	}
+	result.Loss = metric(func(e Evaluation) float64 { return e.Loss })
+	result.Accuracy = metric(func(e Evaluation) float64 { return e.Accuracy })
+	result.RMSE = metric(func(e Evaluation) float64 { return e.RMSE })
+	result.MAE = metric(func(e Evaluation) float64 { return e.MAE })
+	result.R2 = metric(func(e Evaluation) float64 { return e.R2 })
+	result.HammingLoss = metric(func(e Evaluation) float64 { return e.HammingLoss })
+	return result, nil
+}
+
+// assignFolds shuffles the examples of the set and deals them into k folds, returning the columns of each fold
+func assignFolds(set Dataset, k uint, options CrossValidationOptions) [][]int {
+	strata := map[string][]int{}
+	for i := 0; i < int(set.NumberOfExamples()); i++ {
+		key := ""
+		if options.Stratified {
+			key = strings.Join(exampleLabels(set, i), "\x00")
+		}
+		strata[key] = append(strata[key], i)
+	}
+	keys := make([]string, 0, len(strata))
+	for key := range strata {
+		keys = append(keys, key)
+	}
+	sort.Strings(keys)
+
+	rng := rand.New(rand.NewSource(options.Seed))
+	folds := make([][]int, k)
+	next := 0
+	for _, key := range keys {
+		stratum := strata[key]
+		rng.Shuffle(len(stratum), func(i, j int) { stratum[i], stratum[j] = stratum[j], stratum[i] })
+		// Continue dealing from the fold the previous stratum stopped at, so the folds stay the same size
+		for _, column := range stratum {
+			folds[next] = append(folds[next], column)
+			next = (next + 1) % int(k)
+		}
+	}
+	return folds
+}
@@ -0,0 +1,65 @@
+package neuralnet_test
+
+import (
+	"testing"
+
+	"github.com/codehex/neuralnet"
+)
+
+func TestCrossValidate(t *testing.T) {
+	var features [][]float64
+	var labels []string
+	for i := 0; i < 20; i++ {
+		offset := float64(i%5) * 0.1
+		features = append(features, []float64{1 + offset, 1 - offset}, []float64{-1 - offset, -1 + offset})
+		labels = append(labels, "positive", "negative")
+	}
+	set, err := neuralnet.NewClassificationFeatureSet(features, labels, "negative", "positive")
+	if err != nil {
+		t.Fatal(err)
+	}
+	hyperParams, err := neuralnet.NewHyperParametersBuilder().
+		AddLayers(neuralnet.ActivationFuncNameSigmoid, 1).
+		SetLearningRate(0.5).
+		SetIterations(100).
+		SetSeed(1).
+		Build()
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	result, err := neuralnet.CrossValidate(hyperParams, set, 4, neuralnet.CrossValidationOptions{
+		Stratified:  true,
+		Seed:        1,
+		Parallelism: 2,
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(result.Folds) != 4 {
+		t.Fatalf("Expected 4 folds, but got %d", len(result.Folds))
+	}
+	for i, fold := range result.Folds {
+		// Stratified folds hold 5 examples of each class
+		if fold.Examples != 10 {
+			t.Errorf("Expected fold %d to hold 10 examples, but got %d", i+1, fold.Examples)
+		}
+	}
+	if result.Accuracy.Mean != 1 || result.Accuracy.StdDev != 0 {
+		t.Errorf("Expected every fold to be classified perfectly, but got %s", result)
+	}
+
+	again, err := neuralnet.CrossValidate(hyperParams, set, 4, neuralnet.CrossValidationOptions{Stratified: true, Seed: 1})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if again.Loss != result.Loss {
+		t.Errorf("Expected the same seeds to give the same loss, but got %v and %v", result.Loss, again.Loss)
+	}
+
+	for _, k := range []uint{0, 1, 41} {
+		if _, err := neuralnet.CrossValidate(hyperParams, set, k, neuralnet.CrossValidationOptions{}); err == nil {
+			t.Errorf("Expected an error with %d folds", k)
+		}
+	}
+}
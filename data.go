@@ -7,23 +7,23 @@ import (
 	"os"
 	"path"
 
-	"github.com/codehex/neuralnet/mx"
 	"github.com/nfnt/resize"
 )
 
 type entry struct {
-	pathToImage          string
-	flippedHoriz         bool
-	binaryClassification bool
-	featureVector        []float64
+	pathToImage   string
+	flippedHoriz  bool
+	label         string
+	labels        []string
+	target        float64
+	featureVector []float64
 }
 
+// ImageSet is a Dataset of images, whose features are the colour channels of each pixel
 type ImageSet struct {
-	width, height        uint
-	entries              []entry
-	featureCount         uint
-	vectorised           mx.Matrix
-	classificationVector mx.Matrix
+	examples
+	width, height uint
+	normalizer    *Normalizer
 }
 
 type ImageSetBuilder struct {
@@ -34,6 +34,8 @@ type ImageSetBuilder struct {
 	err              error
 	augmentFlipHoriz bool
 	normalize        bool
+	perChannel       bool
+	normalizer       *Normalizer
 }
 
 func NewImageSetBuilder() ImageSetBuilder {
@@ -52,11 +54,37 @@ func (builder ImageSetBuilder) WithPathPrefix(pathPrefix string) ImageSetBuilder
 	return builder
 }
 
-func (builder ImageSetBuilder) AddFolder(pathToFolder string, classification bool) ImageSetBuilder {
+// WithClasses fixes the order of the class labels used when vectorising the labels. By default classes are
+// ordered by first appearance, so sets built separately (e.g. training and test) should either add their
+// folders in the same order or declare the classes explicitly.
+func (builder ImageSetBuilder) WithClasses(labels ...string) ImageSetBuilder {
 	if builder.err != nil {
 		return builder
 	}
-	builder.log(fmt.Sprintf("📁 Adding folder %s with classification %t", pathToFolder, classification))
+	if builder.currentSet.kind == targetValues {
+		builder.err = fmt.Errorf("classes cannot be used with a set with %s", targetValues)
+		return builder
+	}
+	for _, label := range labels {
+		if label == "" {
+			builder.err = fmt.Errorf("class label cannot be empty")
+			return builder
+		}
+		builder.currentSet.addClass(label)
+	}
+	builder.log(fmt.Sprintf("🏷️ Using classes %v", builder.currentSet.classes))
+	return builder
+}
+
+func (builder ImageSetBuilder) AddFolder(pathToFolder string, label string) ImageSetBuilder {
+	if builder.err != nil || !builder.useLabels(classLabels) {
+		return builder
+	}
+	if label == "" {
+		builder.err = fmt.Errorf("class label for folder %s cannot be empty", pathToFolder)
+		return builder
+	}
+	builder.log(fmt.Sprintf("📁 Adding folder %s with label %s", pathToFolder, label))
 	folderPath := path.Join(builder.pathPrefix, pathToFolder)
 	files, err := os.ReadDir(folderPath)
 	if err != nil {
@@ -69,28 +97,148 @@ func (builder ImageSetBuilder) AddFolder(pathToFolder string, classification boo
 		if !file.IsDir() {
 			added += 1
 			builder.currentSet.entries = append(builder.currentSet.entries,
-				entry{pathToImage: path.Join(folderPath, file.Name()), binaryClassification: classification})
+				entry{pathToImage: path.Join(folderPath, file.Name()), label: label})
 		}
 	}
-	builder.log(fmt.Sprintf("- Adding %d image(s) with classification '%t'", added, classification))
+	builder.currentSet.addClass(label)
+	builder.log(fmt.Sprintf("- Adding %d image(s) with label '%s'", added, label))
 	return builder
 }
 
-func (builder ImageSetBuilder) AddImage(pathToImage string, classification bool) ImageSetBuilder {
-	if builder.err != nil {
+func (builder ImageSetBuilder) AddImage(pathToImage string, label string) ImageSetBuilder {
+	if builder.err != nil || !builder.useLabels(classLabels) {
+		return builder
+	}
+	if label == "" {
+		builder.err = fmt.Errorf("class label for image %s cannot be empty", pathToImage)
+		return builder
+	}
+
+	if !fileExists(pathToImage) {
+		builder.err = fmt.Errorf("file %s does not exist", pathToImage)
+		return builder
+	}
+	builder.log(fmt.Sprintf("🖼️ Adding image %s with label %s", pathToImage, label))
+	builder.currentSet.entries = append(builder.currentSet.entries,
+		entry{pathToImage: pathToImage, label: label})
+	builder.currentSet.addClass(label)
+	return builder
+}
+
+// AddFolderWithLabels adds a folder for multi-label classification, where each image has all the given labels
+func (builder ImageSetBuilder) AddFolderWithLabels(pathToFolder string, labels ...string) ImageSetBuilder {
+	if builder.err != nil || !builder.useLabels(multipleLabels) || !builder.checkLabels(pathToFolder, labels) {
+		return builder
+	}
+	builder.log(fmt.Sprintf("📁 Adding folder %s with labels %v", pathToFolder, labels))
+	folderPath := path.Join(builder.pathPrefix, pathToFolder)
+	files, err := os.ReadDir(folderPath)
+	if err != nil {
+		builder.err = fmt.Errorf("error reading directory %s: %w", pathToFolder, err)
 		return builder
 	}
 
+	added := 0
+	for _, file := range files {
+		if !file.IsDir() {
+			added += 1
+			builder.currentSet.entries = append(builder.currentSet.entries,
+				entry{pathToImage: path.Join(folderPath, file.Name()), labels: labels})
+		}
+	}
+	for _, label := range labels {
+		builder.currentSet.addClass(label)
+	}
+	builder.log(fmt.Sprintf("- Adding %d image(s) with labels %v", added, labels))
+	return builder
+}
+
+// AddImageWithLabels adds a single image for multi-label classification, with any number of labels
+func (builder ImageSetBuilder) AddImageWithLabels(pathToImage string, labels ...string) ImageSetBuilder {
+	if builder.err != nil || !builder.useLabels(multipleLabels) || !builder.checkLabels(pathToImage, labels) {
+		return builder
+	}
 	if !fileExists(pathToImage) {
 		builder.err = fmt.Errorf("file %s does not exist", pathToImage)
 		return builder
 	}
-	builder.log(fmt.Sprintf("🖼️ Adding image %s with classification %t", pathToImage, classification))
+	builder.log(fmt.Sprintf("🖼️ Adding image %s with labels %v", pathToImage, labels))
 	builder.currentSet.entries = append(builder.currentSet.entries,
-		entry{pathToImage: pathToImage, binaryClassification: classification})
+		entry{pathToImage: pathToImage, labels: labels})
+	for _, label := range labels {
+		builder.currentSet.addClass(label)
+	}
 	return builder
 }
 
+// checkLabels checks the labels of multi-label images aren't empty
+func (builder *ImageSetBuilder) checkLabels(source string, labels []string) bool {
+	for _, label := range labels {
+		if label == "" {
+			builder.err = fmt.Errorf("class label for %s cannot be empty", source)
+			return false
+		}
+	}
+	return true
+}
+
+// AddImageWithTarget adds a single image with a continuous target value, for regression rather than classification
+func (builder ImageSetBuilder) AddImageWithTarget(pathToImage string, target float64) ImageSetBuilder {
+	if builder.err != nil || !builder.useLabels(targetValues) {
+		return builder
+	}
+	if !fileExists(pathToImage) {
+		builder.err = fmt.Errorf("file %s does not exist", pathToImage)
+		return builder
+	}
+	builder.log(fmt.Sprintf("🖼️ Adding image %s with target %v", pathToImage, target))
+	builder.currentSet.entries = append(builder.currentSet.entries,
+		entry{pathToImage: pathToImage, target: target})
+	return builder
+}
+
+// AddFolderWithTargets adds the images of a folder for regression, where targets maps the file name of each
+// image to its continuous target value
+func (builder ImageSetBuilder) AddFolderWithTargets(pathToFolder string, targets map[string]float64) ImageSetBuilder {
+	if builder.err != nil || !builder.useLabels(targetValues) {
+		return builder
+	}
+	builder.log(fmt.Sprintf("📁 Adding folder %s with targets", pathToFolder))
+	folderPath := path.Join(builder.pathPrefix, pathToFolder)
+	files, err := os.ReadDir(folderPath)
+	if err != nil {
+		builder.err = fmt.Errorf("error reading directory %s: %w", pathToFolder, err)
+		return builder
+	}
+
+	added := 0
+	for _, file := range files {
+		if file.IsDir() {
+			continue
+		}
+		target, ok := targets[file.Name()]
+		if !ok {
+			builder.err = fmt.Errorf("no target for image %s in folder %s", file.Name(), pathToFolder)
+			return builder
+		}
+		added += 1
+		builder.currentSet.entries = append(builder.currentSet.entries,
+			entry{pathToImage: path.Join(folderPath, file.Name()), target: target})
+	}
+	builder.log(fmt.Sprintf("- Adding %d image(s) with targets", added))
+	return builder
+}
+
+// useLabels sets the kind of labels of the set, failing if images with another kind of labels have already been added
+func (builder *ImageSetBuilder) useLabels(kind labelKind) bool {
+	if builder.currentSet.kind != noLabels && builder.currentSet.kind != kind {
+		builder.err = fmt.Errorf("images with %s cannot be added to a set with %s", kind, builder.currentSet.kind)
+		return false
+	}
+	builder.currentSet.kind = kind
+	return true
+}
+
 func (builder ImageSetBuilder) ResizeImages(width, height uint) ImageSetBuilder {
 	if builder.err != nil {
 		return builder
@@ -112,8 +260,47 @@ func (builder ImageSetBuilder) AugmentFlipHorizontal() ImageSetBuilder {
 	return builder
 }
 
+// Normalize standardizes each feature using the mean and standard deviation of this set. The fitted statistics are
+// available from ImageSet.Normalizer so they can be reused for other sets with NormalizeWith.
 func (builder ImageSetBuilder) Normalize() ImageSetBuilder {
 	builder.normalize = true
+	builder.perChannel = false
+	builder.normalizer = nil
+	return builder
+}
+
+// NormalizePerChannel standardizes the features using the mean and standard deviation of each colour channel of
+// this set, rather than of each individual feature
+func (builder ImageSetBuilder) NormalizePerChannel() ImageSetBuilder {
+	builder.normalize = true
+	builder.perChannel = true
+	builder.normalizer = nil
+	return builder
+}
+
+// NormalizeWith standardizes the features using statistics fitted on another set, typically the training set
+func (builder ImageSetBuilder) NormalizeWith(normalizer *Normalizer) ImageSetBuilder {
+	if builder.err != nil {
+		return builder
+	}
+	if normalizer == nil {
+		builder.err = fmt.Errorf("normalizer cannot be nil")
+		return builder
+	}
+	builder.normalize = true
+	builder.normalizer = normalizer
+	return builder
+}
+
+// WithPreprocessing applies the same preprocessing that was used for an existing set, e.g. the one returned by
+// TrainedModel.Preprocessing for a loaded model
+func (builder ImageSetBuilder) WithPreprocessing(p Preprocessing) ImageSetBuilder {
+	if p.Width != 0 || p.Height != 0 {
+		builder = builder.ResizeImages(p.Width, p.Height)
+	}
+	if p.Normalizer != nil {
+		builder = builder.NormalizeWith(p.Normalizer)
+	}
 	return builder
 }
 
@@ -173,11 +360,13 @@ func (builder ImageSetBuilder) Build() (*ImageSet, error) {
 
 	if builder.normalize {
 		builder.log("Normalizing feature vectors...")
-		builder.currentSet.normalize()
+		if err := builder.currentSet.normalize(builder.normalizer, builder.perChannel); err != nil {
+			builder.logError(err)
+			return nil, err
+		}
 	}
 
-	builder.currentSet.vectorised = builder.currentSet.vectoriseExamples()
-	builder.currentSet.classificationVector = builder.currentSet.vectoriseLabels()
+	builder.currentSet.vectorise()
 	builder.log("✅ Done")
 	return builder.currentSet, nil
 }
@@ -242,59 +431,32 @@ func convertImageToFeatures(image image.Image, flippedHoriz bool) []float64 {
 	return vector
 }
 
-func (i *ImageSet) NumberOfExamples() uint {
-	return uint(len(i.entries))
+// Preprocessing returns the steps used to convert the images of the set into feature vectors
+func (i *ImageSet) Preprocessing() Preprocessing {
+	return Preprocessing{Width: i.width, Height: i.height, Normalizer: i.normalizer}
 }
 
-func (i *ImageSet) X() mx.Matrix {
-	return i.vectorised
+// Normalizer returns the statistics used to normalize the set, or nil if it wasn't normalized
+func (i *ImageSet) Normalizer() *Normalizer {
+	return i.normalizer
 }
 
-func (i *ImageSet) Y() mx.Matrix {
-	return i.classificationVector
-}
-
-func (i *ImageSet) vectoriseExamples() mx.Matrix {
-	vectors := make([][]float64, i.NumberOfExamples())
-	for i, entry := range i.entries {
-		vectors[i] = entry.featureVector
-	}
-	return mx.NewHorizontalStackedMatrix(vectors)
-}
-
-func (i *ImageSet) vectoriseLabels() mx.Matrix {
-	labels := make([]float64, i.NumberOfExamples())
-	for i, entry := range i.entries {
-		if entry.binaryClassification {
-			labels[i] = 1
-		} else {
-			labels[i] = 0
+// normalize standardizes the feature vectors of the set, fitting a new normalizer on the set if none is given
+func (i *ImageSet) normalize(normalizer *Normalizer, perChannel bool) error {
+	if normalizer == nil {
+		vectors := make([][]float64, len(i.entries))
+		for index := range i.entries {
+			vectors[index] = i.entries[index].featureVector
 		}
+		normalizer = fitNormalizer(vectors, perChannel)
 	}
-	return mx.NewRowVector(labels)
-}
-
-func (i *ImageSet) normalize() {
-	count := len(i.entries)
-	featureCount := len(i.entries[0].featureVector)
-	sum := make([]float64, featureCount)
-	sumSquared := make([]float64, featureCount)
-
-	// Calulate sum and sum of squares
-	for entryIndex := range i.entries {
-		for featureIndex := range i.entries[entryIndex].featureVector {
-			sum[featureIndex] += i.entries[entryIndex].featureVector[featureIndex]
-			sumSquared[featureIndex] += i.entries[entryIndex].featureVector[featureIndex] * i.entries[entryIndex].featureVector[featureIndex]
-		}
+	if err := normalizer.validate(i.featureCount); err != nil {
+		return err
 	}
 
-	// Normalize each vector entry
-	for entryIndex := range i.entries {
-		for featureIndex := range i.entries[entryIndex].featureVector {
-			mean := sum[featureIndex] / float64(count)
-			variance := sumSquared[featureIndex] / float64(count)
-			oldVal := i.entries[entryIndex].featureVector[featureIndex]
-			i.entries[entryIndex].featureVector[featureIndex] = (oldVal - mean) / variance
-		}
+	for index := range i.entries {
+		normalizer.apply(i.entries[index].featureVector)
 	}
+	i.normalizer = normalizer
+	return nil
 }
@@ -0,0 +1,54 @@
+package neuralnet_test
+
+import (
+	"image/color"
+	"math"
+	"testing"
+
+	"github.com/codehex/neuralnet"
+)
+
+func TestNormalizeWithTrainingStatistics(t *testing.T) {
+	dir := t.TempDir()
+	writeTestImages(t, dir, "train", 10, color.RGBA{100, 100, 100, 255})
+	writeTestImages(t, dir, "test", 3, color.RGBA{200, 200, 200, 255})
+
+	trainingSet, err := neuralnet.NewImageSetBuilder().
+		WithPathPrefix(dir).
+		AddFolder("train", "grey").
+		Normalize().
+		Build()
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	// The training set should have zero mean for every feature
+	X := trainingSet.X()
+	rows, cols := X.Dims()
+	for i := 0; i < rows; i++ {
+		sum := 0.0
+		for j := 0; j < cols; j++ {
+			sum += X.At(i, j)
+		}
+		if math.Abs(sum/float64(cols)) > 1e-9 {
+			t.Fatalf("Expected feature %d of the training set to have zero mean, but got %v", i, sum/float64(cols))
+		}
+	}
+
+	testSet, err := neuralnet.NewImageSetBuilder().
+		WithPathPrefix(dir).
+		AddFolder("test", "grey").
+		NormalizeWith(trainingSet.Normalizer()).
+		Build()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if testSet.Normalizer() != trainingSet.Normalizer() {
+		t.Errorf("Expected the test set to keep the training set normalizer")
+	}
+
+	// The brighter test images are normalized with the training statistics, so they should not be centred on 0
+	if testSet.X().At(0, 0) <= 0 {
+		t.Errorf("Expected test features to be above the training mean, but got %v", testSet.X().At(0, 0))
+	}
+}
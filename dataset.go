@@ -0,0 +1,235 @@
+package neuralnet
+
+import (
+	"reflect"
+
+	"github.com/codehex/neuralnet/mx"
+)
+
+// Dataset is a set of examples that a model can be trained on, evaluated against or make predictions for. It is
+// implemented by ImageSet and FeatureSet, and can be implemented for other sources of data.
+type Dataset interface {
+	// FeatureCount returns the number of features of each example
+	FeatureCount() uint
+	NumberOfExamples() uint
+	// X returns the features, with a row per feature and a column per example
+	X() mx.Matrix
+	// Y returns the labels, with a column per example. For two classes it has a single row set to the class index of
+	// each example, and for more classes a row per class set to 1 for the class of the example. For multiple labels
+	// it has a row per class set to 1 for each label of the example, and for regression a single row of the targets.
+	Y() mx.Matrix
+	// Classes returns the class labels, where the position of each label is its class index. It is empty for
+	// regression.
+	Classes() []string
+	// Regression returns true if the examples have continuous target values rather than class labels
+	Regression() bool
+	// MultiLabel returns true if each example can have any number of class labels
+	MultiLabel() bool
+}
+
+// datasetOrNil returns nil for a nil pointer to a set, such as a nil *ImageSet, which isn't a nil Dataset once it
+// has been converted to the interface
+func datasetOrNil(set Dataset) Dataset {
+	if set == nil {
+		return nil
+	}
+	if v := reflect.ValueOf(set); v.Kind() == reflect.Pointer && v.IsNil() {
+		return nil
+	}
+	return set
+}
+
+// preprocessedSet is implemented by sets that transform their data into features in a way that must be repeated
+// for predictions, which is saved with the trained model
+type preprocessedSet interface {
+	Preprocessing() Preprocessing
+}
+
+// labelKind is the kind of labels the examples of a set have
+type labelKind int
+
+const (
+	noLabels labelKind = iota
+	// classLabels assigns each example a single class
+	classLabels
+	// multipleLabels assigns each example any number of classes
+	multipleLabels
+	// targetValues assigns each example a continuous value, for regression
+	targetValues
+)
+
+func (k labelKind) String() string {
+	switch k {
+	case classLabels:
+		return "class labels"
+	case multipleLabels:
+		return "multiple labels"
+	case targetValues:
+		return "targets"
+	default:
+		return "no labels"
+	}
+}
+
+// datasetKind returns the kind of labels of a set
+func datasetKind(d Dataset) labelKind {
+	switch {
+	case d.Regression():
+		return targetValues
+	case d.MultiLabel():
+		return multipleLabels
+	default:
+		return classLabels
+	}
+}
+
+// examples holds the feature vectors and labels of a set, implementing Dataset for the sets of this package
+type examples struct {
+	entries              []entry
+	classes              []string
+	kind                 labelKind
+	featureCount         uint
+	vectorised           mx.Matrix
+	classificationVector mx.Matrix
+}
+
+func (e *examples) FeatureCount() uint {
+	return e.featureCount
+}
+
+func (e *examples) NumberOfExamples() uint {
+	return uint(len(e.entries))
+}
+
+// Classes returns the class labels of the set, where the position of each label is its class index.
+func (e *examples) Classes() []string {
+	return e.classes
+}
+
+// Regression returns true if the examples of the set have continuous target values rather than class labels
+func (e *examples) Regression() bool {
+	return e.kind == targetValues
+}
+
+// MultiLabel returns true if each example of the set can have any number of class labels
+func (e *examples) MultiLabel() bool {
+	return e.kind == multipleLabels
+}
+
+func (e *examples) X() mx.Matrix {
+	return e.vectorised
+}
+
+func (e *examples) Y() mx.Matrix {
+	return e.classificationVector
+}
+
+// vectorise generates the features and labels matrices once all the examples have been added
+func (e *examples) vectorise() {
+	if e.kind == noLabels {
+		e.kind = classLabels
+	}
+	e.vectorised = e.vectoriseExamples()
+	e.classificationVector = e.vectoriseLabels()
+}
+
+func (e *examples) vectoriseExamples() mx.Matrix {
+	vectors := make([][]float64, e.NumberOfExamples())
+	for i, entry := range e.entries {
+		vectors[i] = entry.featureVector
+	}
+	return mx.NewHorizontalStackedMatrix(vectors)
+}
+
+func (e *examples) addClass(label string) {
+	if e.classIndex(label) < 0 {
+		e.classes = append(e.classes, label)
+	}
+}
+
+func (e *examples) classIndex(label string) int {
+	for index, class := range e.classes {
+		if class == label {
+			return index
+		}
+	}
+	return -1
+}
+
+// vectoriseLabels generates the labels matrix. For two classes this is a row vector, where the second class
+// is the positive one (1), and for more classes it is a one-hot matrix with a row per class. For multiple labels
+// it has a row per class, set to 1 for each label of the example, and for regression it is a row vector of the
+// targets.
+func (e *examples) vectoriseLabels() mx.Matrix {
+	if e.kind == multipleLabels {
+		labels := mx.NewZeroMatrix(uint(len(e.classes)), e.NumberOfExamples())
+		for index, entry := range e.entries {
+			for _, label := range entry.labels {
+				labels.Set(e.classIndex(label), index, 1)
+			}
+		}
+		return labels
+	}
+	if e.kind == targetValues {
+		targets := make([]float64, e.NumberOfExamples())
+		for index, entry := range e.entries {
+			targets[index] = entry.target
+		}
+		return mx.NewRowVector(targets)
+	}
+
+	if len(e.classes) <= 2 {
+		labels := make([]float64, e.NumberOfExamples())
+		for index, entry := range e.entries {
+			labels[index] = float64(e.classIndex(entry.label))
+		}
+		return mx.NewRowVector(labels)
+	}
+
+	labels := mx.NewZeroMatrix(uint(len(e.classes)), e.NumberOfExamples())
+	for index, entry := range e.entries {
+		labels.Set(e.classIndex(entry.label), index, 1)
+	}
+	return labels
+}
+
+// subset is a Dataset made of some of the examples of another set
+type subset struct {
+	Dataset
+	x, y mx.Matrix
+}
+
+// newSubset copies the examples in the given columns of the set into a new set
+func newSubset(set Dataset, columns []int) *subset {
+	features, _ := set.X().Dims()
+	labels, _ := set.Y().Dims()
+	s := &subset{
+		Dataset: set,
+		x:       mx.NewZeroMatrix(uint(features), uint(len(columns))),
+		y:       mx.NewZeroMatrix(uint(labels), uint(len(columns))),
+	}
+	s.x.CopyColumns(set.X(), columns)
+	s.y.CopyColumns(set.Y(), columns)
+	return s
+}
+
+func (s *subset) NumberOfExamples() uint {
+	_, columns := s.x.Dims()
+	return uint(columns)
+}
+
+func (s *subset) X() mx.Matrix {
+	return s.x
+}
+
+func (s *subset) Y() mx.Matrix {
+	return s.y
+}
+
+// Preprocessing returns the preprocessing of the set the examples were taken from, if it has one
+func (s *subset) Preprocessing() Preprocessing {
+	if set, ok := s.Dataset.(preprocessedSet); ok {
+		return set.Preprocessing()
+	}
+	return Preprocessing{}
+}
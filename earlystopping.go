@@ -0,0 +1,40 @@
+package neuralnet
+
+import "fmt"
+
+// earlyStoppingConfig holds the early stopping settings chosen on the HyperParametersBuilder. A patience of 0
+// disables early stopping.
+type earlyStoppingConfig struct {
+	patience uint
+	minDelta float64
+}
+
+func (c earlyStoppingConfig) String() string {
+	return fmt.Sprintf("patience %d, min delta %.5g", c.patience, c.minDelta)
+}
+
+// earlyStopping tracks the validation loss during training, keeping a copy of the parameters with the lowest loss
+type earlyStopping struct {
+	config        earlyStoppingConfig
+	bestLoss      float64
+	bestIteration uint
+	best          *parameters
+	sinceBest     uint
+}
+
+// check records the evaluation of the validation set after the given iteration, returning true if the
+// training should stop as the loss hasn't improved for patience iterations
+func (e *earlyStopping) check(iter uint, eval Evaluation, params *parameters) bool {
+	if e.config.patience == 0 {
+		return false
+	}
+	if e.best == nil || eval.Loss < e.bestLoss-e.config.minDelta {
+		e.bestLoss = eval.Loss
+		e.bestIteration = iter
+		e.best = params.clone()
+		e.sinceBest = 0
+		return false
+	}
+	e.sinceBest++
+	return e.sinceBest >= e.config.patience
+}
@@ -17,6 +17,7 @@ func main() {
 		SetDropoutKeepProbability(0.75).
 		SetMiniBatchSize(1024).
 		UseGradientDescentWithMomentum(0.9).
+		WithLogging().
 		Build()
 
 	if err != nil {
@@ -28,8 +29,8 @@ func main() {
 	trainingDataSet, err := neuralnet.NewImageSetBuilder().
 		AugmentFlipHorizontal().
 		WithPathPrefix("../datasets/Vegetable Images/train").
-		AddFolder("Cabbage", false).
-		AddFolder("Carrot", true).
+		AddFolder("Cabbage", "cabbage").
+		AddFolder("Carrot", "carrot").
 		ResizeImages(64, 64).
 		Normalize().
 		Build()
@@ -39,21 +40,25 @@ func main() {
 	}
 
 	// Use hyperparameters to train model
-	model, err := hyperParams.TrainModel(trainingDataSet)
+	model, err := hyperParams.TrainModel(trainingDataSet, nil)
 
 	if err != nil {
 		panic(err)
 	}
 
-	model.Predict(trainingDataSet)
+	trainingEval, err := model.Evaluate(trainingDataSet)
+	if err != nil {
+		panic(err)
+	}
+	fmt.Println("training set:", trainingEval)
 
 	// Read test data
 	testDataSet, err := neuralnet.NewImageSetBuilder().
 		WithPathPrefix("../datasets/Vegetable Images/test").
-		AddFolder("Cabbage", false).
-		AddFolder("Carrot", true).
+		AddFolder("Cabbage", "cabbage").
+		AddFolder("Carrot", "carrot").
 		ResizeImages(64, 64).
-		Normalize().
+		NormalizeWith(trainingDataSet.Normalizer()).
 		Build()
 
 	if err != nil {
@@ -61,5 +66,9 @@ func main() {
 	}
 
 	// Use model to generate predictions
-	model.Predict(testDataSet)
+	testEval, err := model.Evaluate(testDataSet)
+	if err != nil {
+		panic(err)
+	}
+	fmt.Println("test set:", testEval)
 }
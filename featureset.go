@@ -0,0 +1,97 @@
+package neuralnet
+
+import (
+	"errors"
+	"fmt"
+)
+
+// FeatureSet is a Dataset of feature vectors held in memory, for data that doesn't come from images such as
+// tabular or synthetic data
+type FeatureSet struct {
+	examples
+}
+
+// NewClassificationFeatureSet creates a set from feature vectors and the class label of each vector. The classes
+// are ordered by first appearance, unless they are given.
+func NewClassificationFeatureSet(features [][]float64, labels []string, classes ...string) (*FeatureSet, error) {
+	if len(labels) != len(features) {
+		return nil, fmt.Errorf("found %d labels for %d feature vectors", len(labels), len(features))
+	}
+	set, err := newFeatureSet(features, classLabels, classes)
+	if err != nil {
+		return nil, err
+	}
+	for i, label := range labels {
+		if label == "" {
+			return nil, fmt.Errorf("class label of feature vector %d cannot be empty", i)
+		}
+		set.entries[i].label = label
+		set.addClass(label)
+	}
+	set.vectorise()
+	return set, nil
+}
+
+// NewMultiLabelFeatureSet creates a set from feature vectors and any number of class labels for each vector. The
+// classes are ordered by first appearance, unless they are given.
+func NewMultiLabelFeatureSet(features [][]float64, labels [][]string, classes ...string) (*FeatureSet, error) {
+	if len(labels) != len(features) {
+		return nil, fmt.Errorf("found labels for %d feature vectors, but there are %d", len(labels), len(features))
+	}
+	set, err := newFeatureSet(features, multipleLabels, classes)
+	if err != nil {
+		return nil, err
+	}
+	for i := range labels {
+		for _, label := range labels[i] {
+			if label == "" {
+				return nil, fmt.Errorf("class label of feature vector %d cannot be empty", i)
+			}
+			set.addClass(label)
+		}
+		set.entries[i].labels = labels[i]
+	}
+	set.vectorise()
+	return set, nil
+}
+
+// NewRegressionFeatureSet creates a set from feature vectors and the continuous target value of each vector
+func NewRegressionFeatureSet(features [][]float64, targets []float64) (*FeatureSet, error) {
+	if len(targets) != len(features) {
+		return nil, fmt.Errorf("found %d targets for %d feature vectors", len(targets), len(features))
+	}
+	set, err := newFeatureSet(features, targetValues, nil)
+	if err != nil {
+		return nil, err
+	}
+	for i, target := range targets {
+		set.entries[i].target = target
+	}
+	set.vectorise()
+	return set, nil
+}
+
+// newFeatureSet creates a set with an entry per feature vector, checking they all have the same number of features
+func newFeatureSet(features [][]float64, kind labelKind, classes []string) (*FeatureSet, error) {
+	if len(features) == 0 {
+		return nil, errors.New("feature set must have at least one feature vector")
+	}
+	set := &FeatureSet{examples{kind: kind, featureCount: uint(len(features[0]))}}
+	if set.featureCount == 0 {
+		return nil, errors.New("feature vectors must have at least one feature")
+	}
+	for _, class := range classes {
+		if class == "" {
+			return nil, errors.New("class label cannot be empty")
+		}
+		set.addClass(class)
+	}
+	set.entries = make([]entry, len(features))
+	for i, vector := range features {
+		if uint(len(vector)) != set.featureCount {
+			return nil, fmt.Errorf("feature vector %d has %d features, but expected %d", i, len(vector), set.featureCount)
+		}
+		set.entries[i].featureVector = vector
+	}
+	return set, nil
+}
@@ -0,0 +1,121 @@
+package neuralnet_test
+
+import (
+	"testing"
+
+	"github.com/codehex/neuralnet"
+)
+
+func TestTrainModelOnFeatureSet(t *testing.T) {
+	// Points are labelled by the quadrant they are in, which isn't linearly separable
+	var features [][]float64
+	var labels []string
+	for _, x := range []float64{-1, -0.5, 0.5, 1} {
+		for _, y := range []float64{-1, -0.5, 0.5, 1} {
+			features = append(features, []float64{x, y})
+			if x*y > 0 {
+				labels = append(labels, "same")
+			} else {
+				labels = append(labels, "different")
+			}
+		}
+	}
+	set, err := neuralnet.NewClassificationFeatureSet(features, labels, "different", "same")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if set.FeatureCount() != 2 || set.NumberOfExamples() != 16 {
+		t.Fatalf("Expected 16 examples with 2 features, but got %d with %d", set.NumberOfExamples(), set.FeatureCount())
+	}
+
+	hyperParams, err := neuralnet.NewHyperParametersBuilder().
+		AddLayers(neuralnet.ActivationFuncNameTanh, 8).
+		AddLayers(neuralnet.ActivationFuncNameSigmoid, 1).
+		UseAdam(0.9, 0.999, 1e-8).
+		SetLearningRate(0.05).
+		SetIterations(300).
+		SetSeed(1).
+		Build()
+	if err != nil {
+		t.Fatal(err)
+	}
+	model, err := hyperParams.TrainModel(set, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	eval, err := model.Evaluate(set)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if eval.Accuracy < 1 {
+		t.Errorf("Expected the model to separate the quadrants, but got %v", eval)
+	}
+	predictions, err := model.Predict(set)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if predictions[0].Label != "same" || predictions[0].PathToImage != "" {
+		t.Errorf("Expected the first point to be predicted as same, without an image, but got %+v", predictions[0])
+	}
+}
+
+func TestNewFeatureSetInvalid(t *testing.T) {
+	if _, err := neuralnet.NewClassificationFeatureSet([][]float64{{1, 2}, {3}}, []string{"a", "b"}); err == nil {
+		t.Errorf("Expected an error for feature vectors of different lengths")
+	}
+	if _, err := neuralnet.NewRegressionFeatureSet([][]float64{{1}, {2}}, []float64{1}); err == nil {
+		t.Errorf("Expected an error for a missing target")
+	}
+}
+
+func TestTrainModelValidationSetKindMismatch(t *testing.T) {
+	training, err := neuralnet.NewClassificationFeatureSet([][]float64{{0}, {1}}, []string{"a", "b"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	validation, err := neuralnet.NewRegressionFeatureSet([][]float64{{0}, {1}}, []float64{0, 1})
+	if err != nil {
+		t.Fatal(err)
+	}
+	hyperParams, err := neuralnet.NewHyperParametersBuilder().
+		AddLayers(neuralnet.ActivationFuncNameSigmoid, 1).
+		Build()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, err := hyperParams.TrainModel(training, validation); err == nil {
+		t.Errorf("Expected an error when the validation set has targets and the training set class labels")
+	}
+}
+
+// unlabelledSet is a custom dataset that doesn't report the classes of its labels
+type unlabelledSet struct {
+	*neuralnet.FeatureSet
+}
+
+func (unlabelledSet) Classes() []string {
+	return nil
+}
+
+func TestTrainModelClassesMismatch(t *testing.T) {
+	twoClasses, err := neuralnet.NewClassificationFeatureSet([][]float64{{0}, {1}}, []string{"a", "b"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	oneClass, err := neuralnet.NewClassificationFeatureSet([][]float64{{0}, {1}}, []string{"a", "a"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	hyperParams, err := neuralnet.NewHyperParametersBuilder().
+		AddLayers(neuralnet.ActivationFuncNameSigmoid, 1).
+		Build()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, err := hyperParams.TrainModel(unlabelledSet{twoClasses}, nil); err == nil {
+		t.Errorf("Expected an error for a dataset without classes")
+	}
+	if _, err := hyperParams.TrainModel(oneClass, nil); err == nil {
+		t.Errorf("Expected an error for a single class with a single output")
+	}
+}
@@ -5,3 +5,5 @@ go 1.20
 require github.com/nfnt/resize v0.0.0-20180221191011-83c6a9932646
 
 require gonum.org/v1/gonum v0.13.0
+
+require gopkg.in/yaml.v3 v3.0.1
@@ -3,15 +3,7 @@ package neuralnet
 import (
 	"errors"
 	"fmt"
-	"math"
-)
-
-type ActivationFuncName string
-
-const (
-	ActivationFuncNameReLU    ActivationFuncName = "relu"
-	ActivationFuncNameSigmoid ActivationFuncName = "sigmoid"
-	ActivationFuncNameTanh    ActivationFuncName = "tanh"
+	"time"
 )
 
 type layerDefinition struct {
@@ -19,7 +11,8 @@ type layerDefinition struct {
 	actFuncLabel      ActivationFuncName
 	activationFunc    func(float64) float64
 	activationDerFunc func(float64) float64
-	initFactor        func(uint) float64
+	initializer       Initializer
+	batchNorm         bool
 }
 
 type HyperParameters struct {
@@ -28,8 +21,18 @@ type HyperParameters struct {
 	iterations           uint
 	regularizationFactor float64
 	keepProb             float64
+	batchNorm            batchNormConfig
 	miniBatchSize        uint
-	momentumBeta         float64
+	noShuffle            bool
+	loss                 Loss
+	optimizer            optimizerConfig
+	schedule             scheduleConfig
+	earlyStopping        earlyStoppingConfig
+	callbacks            []TrainingCallback
+	timeBudget           time.Duration
+	checkpoint           checkpointConfig
+	seed                 int64
+	seeded               bool
 }
 
 type HyperParametersBuilder struct {
@@ -41,14 +44,22 @@ func NewHyperParametersBuilder() HyperParametersBuilder {
 		params: HyperParameters{
 			learningRate: 0.01,
 			iterations:   1000,
+			optimizer:    optimizerConfig{name: OptimizerNameGradientDescent},
+			schedule:     scheduleConfig{name: ScheduleNameConstant},
+			batchNorm:    batchNormConfig{momentum: 0.9, epsilon: 1e-5},
 		},
 	}
 }
 
 func (builder HyperParametersBuilder) AddLayers(a ActivationFuncName, neurons ...uint) HyperParametersBuilder {
+	return builder.AddLayersWithInitializer(a, defaultInitializer(a), neurons...)
+}
+
+// AddLayersWithInitializer adds layers like AddLayers, initializing their weights with the given initializer
+// rather than the default for the activation function
+func (builder HyperParametersBuilder) AddLayersWithInitializer(a ActivationFuncName, init Initializer, neurons ...uint) HyperParametersBuilder {
 	for _, n := range neurons {
-		initFactor := calculateInitFactor(a)
-		layer := layerDefinition{n, a, getActivationFunc(a), getActivationDerFunc(a), initFactor}
+		layer := layerDefinition{neurons: n, actFuncLabel: a, initializer: init}
 		builder.params.layers = append(builder.params.layers, layer)
 	}
 	return builder
@@ -56,13 +67,38 @@ func (builder HyperParametersBuilder) AddLayers(a ActivationFuncName, neurons ..
 
 func (builder HyperParametersBuilder) AddNLayers(a ActivationFuncName, neurons uint, n uint) HyperParametersBuilder {
 	for i := uint(0); i < n; i++ {
-		initFactor := calculateInitFactor(a)
-		layer := layerDefinition{n, a, getActivationFunc(a), getActivationDerFunc(a), initFactor}
+		layer := layerDefinition{neurons: neurons, actFuncLabel: a, initializer: defaultInitializer(a)}
 		builder.params.layers = append(builder.params.layers, layer)
 	}
 	return builder
 }
 
+// AddBatchNormLayers adds layers like AddLayers, normalizing the inputs of their activation function to zero mean and
+// unit variance over each mini-batch, then scaling and shifting them by learned parameters. Predictions use the
+// running mean and variance of the mini-batches seen in training instead.
+func (builder HyperParametersBuilder) AddBatchNormLayers(a ActivationFuncName, neurons ...uint) HyperParametersBuilder {
+	for _, n := range neurons {
+		layer := layerDefinition{neurons: n, actFuncLabel: a, initializer: defaultInitializer(a), batchNorm: true}
+		builder.params.layers = append(builder.params.layers, layer)
+	}
+	return builder
+}
+
+// AddNBatchNormLayers adds n batch normalized layers with the same number of neurons, see AddBatchNormLayers
+func (builder HyperParametersBuilder) AddNBatchNormLayers(a ActivationFuncName, neurons uint, n uint) HyperParametersBuilder {
+	for i := uint(0); i < n; i++ {
+		builder = builder.AddBatchNormLayers(a, neurons)
+	}
+	return builder
+}
+
+// SetBatchNormalization sets the momentum of the running mean and variance of batch normalized layers, and the
+// epsilon added to the variance (0.9 and 1e-5 by default)
+func (builder HyperParametersBuilder) SetBatchNormalization(momentum, epsilon float64) HyperParametersBuilder {
+	builder.params.batchNorm = batchNormConfig{momentum: momentum, epsilon: epsilon}
+	return builder
+}
+
 func (builder HyperParametersBuilder) SetLearningRate(learningRate float64) HyperParametersBuilder {
 	builder.params.learningRate = learningRate
 	return builder
@@ -88,16 +124,174 @@ func (builder HyperParametersBuilder) SetMiniBatchSize(size uint) HyperParameter
 	return builder
 }
 
+// WithoutShuffling keeps the examples in the same mini-batches every epoch, in the order of the training set. By
+// default they are shuffled into new mini-batches at the start of every epoch.
+func (builder HyperParametersBuilder) WithoutShuffling() HyperParametersBuilder {
+	builder.params.noShuffle = true
+	return builder
+}
+
+// SetLoss sets the loss minimized by the training, instead of the cross-entropy matching the activation function
+// of the last layer
+func (builder HyperParametersBuilder) SetLoss(loss Loss) HyperParametersBuilder {
+	builder.params.loss = loss
+	return builder
+}
+
 func (builder HyperParametersBuilder) UseGradientDescentWithMomentum(beta float64) HyperParametersBuilder {
-	builder.params.momentumBeta = beta
+	if beta == 0 {
+		builder.params.optimizer = optimizerConfig{name: OptimizerNameGradientDescent}
+		return builder
+	}
+	builder.params.optimizer = optimizerConfig{name: OptimizerNameMomentum, beta1: beta}
+	return builder
+}
+
+// UseNesterovMomentum uses momentum, evaluating the gradient step ahead along the updated velocity
+func (builder HyperParametersBuilder) UseNesterovMomentum(beta float64) HyperParametersBuilder {
+	builder.params.optimizer = optimizerConfig{name: OptimizerNameNesterov, beta1: beta}
 	return builder
 }
 
+// UseRMSProp scales the learning rate of each parameter by an exponential moving average of its squared gradients
+// (typically beta 0.9 and epsilon 1e-8)
+func (builder HyperParametersBuilder) UseRMSProp(beta, epsilon float64) HyperParametersBuilder {
+	builder.params.optimizer = optimizerConfig{name: OptimizerNameRMSProp, beta1: beta, epsilon: epsilon}
+	return builder
+}
+
+// UseAdaGrad scales the learning rate of each parameter by the sum of all its squared gradients
+// (typically epsilon 1e-8)
+func (builder HyperParametersBuilder) UseAdaGrad(epsilon float64) HyperParametersBuilder {
+	builder.params.optimizer = optimizerConfig{name: OptimizerNameAdaGrad, epsilon: epsilon}
+	return builder
+}
+
+// UseAdam combines momentum (beta1) with RMSProp (beta2), with bias correction
+// (typically beta1 0.9, beta2 0.999 and epsilon 1e-8)
+func (builder HyperParametersBuilder) UseAdam(beta1, beta2, epsilon float64) HyperParametersBuilder {
+	builder.params.optimizer = optimizerConfig{name: OptimizerNameAdam, beta1: beta1, beta2: beta2, epsilon: epsilon}
+	return builder
+}
+
+// UseAdamW uses Adam with decoupled weight decay, shrinking the weights directly rather than through the gradients
+func (builder HyperParametersBuilder) UseAdamW(beta1, beta2, epsilon, weightDecay float64) HyperParametersBuilder {
+	builder.params.optimizer = optimizerConfig{
+		name: OptimizerNameAdamW, beta1: beta1, beta2: beta2, epsilon: epsilon, weightDecay: weightDecay,
+	}
+	return builder
+}
+
+// UseStepDecay multiplies the learning rate by factor every interval epochs (or batches)
+func (builder HyperParametersBuilder) UseStepDecay(factor float64, interval uint) HyperParametersBuilder {
+	builder.params.schedule.name = ScheduleNameStepDecay
+	builder.params.schedule.decayRate = factor
+	builder.params.schedule.decayInterval = interval
+	return builder
+}
+
+// UseExponentialDecay multiplies the learning rate by rate every epoch (or batch)
+func (builder HyperParametersBuilder) UseExponentialDecay(rate float64) HyperParametersBuilder {
+	builder.params.schedule.name = ScheduleNameExponentialDecay
+	builder.params.schedule.decayRate = rate
+	return builder
+}
+
+// UseCosineAnnealing decreases the learning rate along a cosine curve, reaching minLearningRate at the end of training
+func (builder HyperParametersBuilder) UseCosineAnnealing(minLearningRate float64) HyperParametersBuilder {
+	builder.params.schedule.name = ScheduleNameCosineAnnealing
+	builder.params.schedule.minLearningRate = minLearningRate
+	return builder
+}
+
+// UseInverseTimeDecay divides the learning rate by (1 + rate * t), where t is the epoch (or batch)
+func (builder HyperParametersBuilder) UseInverseTimeDecay(rate float64) HyperParametersBuilder {
+	builder.params.schedule.name = ScheduleNameInverseTimeDecay
+	builder.params.schedule.decayRate = rate
+	return builder
+}
+
+// UseLearningRateSchedule replaces the learning rate with the value returned by the schedule. Any warmup is
+// still applied on top of it.
+func (builder HyperParametersBuilder) UseLearningRateSchedule(schedule LearningRateSchedule) HyperParametersBuilder {
+	builder.params.schedule.name = ScheduleNameCustom
+	builder.params.schedule.custom = schedule
+	return builder
+}
+
+// UseLinearWarmup scales the learning rate up linearly over the first epochs (or batches) of training
+func (builder HyperParametersBuilder) UseLinearWarmup(duration uint) HyperParametersBuilder {
+	builder.params.schedule.warmup = duration
+	return builder
+}
+
+// DecayLearningRatePerBatch evaluates the learning rate schedule and warmup per mini-batch rather than per epoch
+func (builder HyperParametersBuilder) DecayLearningRatePerBatch() HyperParametersBuilder {
+	builder.params.schedule.perBatch = true
+	return builder
+}
+
+// UseEarlyStopping stops training once the loss of the validation set hasn't improved by more than minDelta for
+// patience iterations, returning the model with the weights that had the lowest validation loss
+func (builder HyperParametersBuilder) UseEarlyStopping(patience uint, minDelta float64) HyperParametersBuilder {
+	builder.params.earlyStopping = earlyStoppingConfig{patience: patience, minDelta: minDelta}
+	return builder
+}
+
+// SetSeed seeds all the randomness used in training (weight initialization, dropout and mini-batch shuffling), so
+// two runs with the same seed, hyperparameters and data produce identical models. Without a seed, every run is
+// seeded from the current time.
+func (builder HyperParametersBuilder) SetSeed(seed int64) HyperParametersBuilder {
+	builder.params.seed = seed
+	builder.params.seeded = true
+	return builder
+}
+
+// SetTimeBudget limits the wall-clock time spent training, stopping at the next mini-batch once it has run out.
+// 0 indicates no limit.
+func (builder HyperParametersBuilder) SetTimeBudget(budget time.Duration) HyperParametersBuilder {
+	builder.params.timeBudget = budget
+	return builder
+}
+
+// SetCheckpointing writes a checkpoint to the directory every given number of iterations, and when the training is
+// cancelled or runs out of time, so it can be continued with ResumeTraining. An interrupted iteration isn't saved, it
+// runs again when the training is resumed.
+func (builder HyperParametersBuilder) SetCheckpointing(dir string, every uint) HyperParametersBuilder {
+	builder.params.checkpoint = checkpointConfig{dir: dir, every: every}
+	return builder
+}
+
+// AddCallbacks registers callbacks that are notified as the training progresses
+func (builder HyperParametersBuilder) AddCallbacks(callbacks ...TrainingCallback) HyperParametersBuilder {
+	// Copy the callbacks, so builders branched from the same parent don't share them
+	builder.params.callbacks = append(append([]TrainingCallback{}, builder.params.callbacks...), callbacks...)
+	return builder
+}
+
+// WithLogging prints the cost of the training to the console every 100 iterations
+func (builder HyperParametersBuilder) WithLogging() HyperParametersBuilder {
+	return builder.AddCallbacks(consoleLogger{every: 100})
+}
+
 func (builder HyperParametersBuilder) Build() (HyperParameters, error) {
-	for _, layer := range builder.params.layers {
+	// Copy the layers before resolving their activation functions, so the builder isn't modified
+	builder.params.layers = append([]layerDefinition{}, builder.params.layers...)
+	for i, layer := range builder.params.layers {
 		if layer.neurons == 0 {
 			return HyperParameters{}, errors.New("layer with 0 neurons defined")
 		}
+		if layer.actFuncLabel != ActivationFuncNameSoftmax {
+			act, err := lookupActivation(layer.actFuncLabel)
+			if err != nil {
+				return HyperParameters{}, err
+			}
+			builder.params.layers[i].activationFunc = act.f
+			builder.params.layers[i].activationDerFunc = act.df
+		}
+		if err := layer.initializer.validate(); err != nil {
+			return HyperParameters{}, err
+		}
 	}
 
 	if builder.params.learningRate <= 0 {
@@ -112,12 +306,44 @@ func (builder HyperParametersBuilder) Build() (HyperParameters, error) {
 		return HyperParameters{}, errors.New("no layers defined")
 	}
 
-	if builder.params.layers[len(builder.params.layers)-1].actFuncLabel != ActivationFuncNameSigmoid {
-		return HyperParameters{}, errors.New("last layer must have sigmoid activation function")
+	if err := builder.params.optimizer.validate(); err != nil {
+		return HyperParameters{}, err
+	}
+
+	if err := builder.params.schedule.validate(builder.params.learningRate); err != nil {
+		return HyperParameters{}, err
+	}
+
+	if builder.params.timeBudget < 0 {
+		return HyperParameters{}, errors.New("time budget cannot be negative")
+	}
+
+	if builder.params.checkpoint.dir != "" && builder.params.checkpoint.every == 0 {
+		return HyperParameters{}, errors.New("checkpoint interval must be greater than 0")
+	}
+
+	if builder.params.earlyStopping.minDelta < 0 {
+		return HyperParameters{}, errors.New("early stopping min delta cannot be negative")
+	}
+
+	for _, layer := range builder.params.layers[:len(builder.params.layers)-1] {
+		if layer.actFuncLabel == ActivationFuncNameSoftmax {
+			return HyperParameters{}, errors.New("softmax activation function can only be used in the last layer")
+		}
 	}
 
-	if builder.params.layers[len(builder.params.layers)-1].neurons != 1 {
-		return HyperParameters{}, errors.New("last layer must have 1 neuron")
+	lastLayer := builder.params.layers[len(builder.params.layers)-1]
+	if lastLayer.batchNorm {
+		return HyperParameters{}, errors.New("batch normalization cannot be used in the last layer")
+	}
+	if err := builder.params.batchNorm.validate(); err != nil {
+		return HyperParameters{}, err
+	}
+	if builder.params.loss.Name == "" {
+		builder.params.loss = defaultLoss(lastLayer.actFuncLabel)
+	}
+	if err := builder.params.loss.validate(lastLayer); err != nil {
+		return HyperParameters{}, err
 	}
 	return builder.params, nil
 }
@@ -126,71 +352,74 @@ func (h HyperParameters) String() string {
 	title := "Hyperparameters:\n"
 	title += fmt.Sprintf("  number of layers: %d\n", len(h.layers))
 	title += fmt.Sprintf("  learning rate: %.5g\n", h.learningRate)
+	if h.schedule.name != ScheduleNameConstant || h.schedule.warmup > 0 {
+		title += fmt.Sprintf("  learning rate schedule: %s\n", h.schedule)
+	}
 	title += fmt.Sprintf("  iterations: %d\n", h.iterations)
+	title += fmt.Sprintf("  loss: %s\n", h.loss)
 	if h.regularizationFactor > 0 {
 		title += fmt.Sprintf("  L2 regularization factor: %.5g\n", h.regularizationFactor)
 	}
 	if h.keepProb > 0 {
 		title += fmt.Sprintf("  dropout keep probability: %.5g\n", h.keepProb)
 	}
+	if h.batchNormalized() {
+		title += fmt.Sprintf("  batch normalization: %s\n", h.batchNorm)
+	}
 	if h.miniBatchSize > 0 {
 		title += fmt.Sprintf("  mini-batch size: %d\n", h.miniBatchSize)
+		if h.noShuffle {
+			title += "  mini-batches not shuffled\n"
+		}
+	}
+	if h.optimizer.name != OptimizerNameGradientDescent {
+		title += fmt.Sprintf("  optimizer: %s\n", h.optimizer)
+	}
+	if h.seeded {
+		title += fmt.Sprintf("  seed: %d\n", h.seed)
 	}
-	if h.momentumBeta > 0 {
-		title += fmt.Sprintf("  gradient descent using momentum: %.5g\n", h.momentumBeta)
+	if h.timeBudget > 0 {
+		title += fmt.Sprintf("  time budget: %s\n", h.timeBudget)
+	}
+	if h.checkpoint.dir != "" {
+		title += fmt.Sprintf("  checkpoint: every %d iteration(s) to %s\n", h.checkpoint.every, h.checkpoint.dir)
+	}
+	if h.earlyStopping.patience > 0 {
+		title += fmt.Sprintf("  early stopping: %s\n", h.earlyStopping)
 	}
 	layers := ""
 	for i := range h.layers {
-		layers += fmt.Sprintf("  layer %d - %d neuron(s), %s activation function\n",
-			i+1, h.layers[i].neurons, h.layers[i].actFuncLabel)
+		layers += fmt.Sprintf("  layer %d - %d neuron(s), %s activation function, %s initializer\n",
+			i+1, h.layers[i].neurons, h.layers[i].actFuncLabel, h.layers[i].initializer)
 	}
 	return title + "\n" + layers
 }
 
-func (h HyperParameters) Layer(i int) layerDefinition {
-	return h.layers[i-1]
-}
-
-func (l layerDefinition) ActivationFunc() func(float64) float64 {
-	return l.activationFunc
-}
-
-func (l layerDefinition) ActivationDerivativeFunc() func(float64) float64 {
-	return l.activationDerFunc
+// batchNormalized returns true if any of the layers uses batch normalization
+func (h HyperParameters) batchNormalized() bool {
+	for _, layer := range h.layers {
+		if layer.batchNorm {
+			return true
+		}
+	}
+	return false
 }
 
-func getActivationFunc(a ActivationFuncName) func(float64) float64 {
-	switch a {
-	case ActivationFuncNameReLU:
-		return relu
-	case ActivationFuncNameSigmoid:
-		return sigmoid
-	case ActivationFuncNameTanh:
-		return tanh
-	default:
-		return relu
+// newSeed returns the seed of the random generators of a training run, the seed if one was set or else the current
+// time
+func (h HyperParameters) newSeed() int64 {
+	if h.seeded {
+		return h.seed
 	}
+	return time.Now().UnixNano()
 }
 
-func getActivationDerFunc(a ActivationFuncName) func(float64) float64 {
-	switch a {
-	case ActivationFuncNameReLU:
-		return reluDerivative
-	case ActivationFuncNameSigmoid:
-		return sigmoidDerivative
-	case ActivationFuncNameTanh:
-		return tanhDerivative
-	default:
-		return relu
-	}
+func (h HyperParameters) Layer(i int) layerDefinition {
+	return h.layers[i-1]
 }
 
-func calculateInitFactor(a ActivationFuncName) func(uint) float64 {
-	var numerator float64 = 1.0
-	if a == ActivationFuncNameReLU {
-		numerator = 2.0
-	}
-	return func(n uint) float64 { return math.Sqrt(numerator / float64(n)) }
+func (h HyperParameters) outputLayer() layerDefinition {
+	return h.layers[len(h.layers)-1]
 }
 
 func (h HyperParameters) generateNodes(featureCount uint) []uint {
@@ -201,33 +430,3 @@ func (h HyperParameters) generateNodes(featureCount uint) []uint {
 	}
 	return nodes
 }
-
-func relu(z float64) float64 {
-	if z > 0 {
-		return z
-	}
-	return 0
-}
-
-func reluDerivative(z float64) float64 {
-	if z > 0 {
-		return 1
-	}
-	return 0
-}
-
-func sigmoid(z float64) float64 {
-	return 1 / (1 + math.Exp(-z))
-}
-
-func sigmoidDerivative(z float64) float64 {
-	return sigmoid(z) * (1 - sigmoid(z))
-}
-
-func tanh(z float64) float64 {
-	return math.Tanh(z)
-}
-
-func tanhDerivative(z float64) float64 {
-	return 1 - math.Pow(tanh(z), 2)
-}
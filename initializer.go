@@ -0,0 +1,87 @@
+package neuralnet
+
+import (
+	"fmt"
+	"math"
+	"math/rand"
+	"strings"
+
+	"github.com/codehex/neuralnet/mx"
+)
+
+type InitializerName string
+
+const (
+	InitializerNameHeNormal      InitializerName = "he-normal"
+	InitializerNameHeUniform     InitializerName = "he-uniform"
+	InitializerNameXavierNormal  InitializerName = "xavier-normal"
+	InitializerNameXavierUniform InitializerName = "xavier-uniform"
+	InitializerNameLeCunNormal   InitializerName = "lecun-normal"
+	InitializerNameLeCunUniform  InitializerName = "lecun-uniform"
+	InitializerNameOrthogonal    InitializerName = "orthogonal"
+	InitializerNameZeros         InitializerName = "zeros"
+	InitializerNameConstant      InitializerName = "constant"
+)
+
+// Initializer chooses how the weights of a layer are initialized before training. Xavier initialization is also
+// known as Glorot initialization.
+type Initializer struct {
+	Name InitializerName `json:"name" yaml:"name"`
+	// Value is the value of every weight when using the constant initializer
+	Value float64 `json:"value,omitempty" yaml:"value,omitempty"`
+}
+
+// defaultInitializer returns the initializer suited to the activation function, He for the relu family and LeCun
+// otherwise, matching how the weights were initialized before initializers could be chosen
+func defaultInitializer(a ActivationFuncName) Initializer {
+	switch {
+	case a == ActivationFuncNameReLU || a == ActivationFuncNameELU || a == ActivationFuncNameLeakyReLU ||
+		strings.HasPrefix(string(a), leakyReLUPrefix):
+		return Initializer{Name: InitializerNameHeNormal}
+	default:
+		return Initializer{Name: InitializerNameLeCunNormal}
+	}
+}
+
+func (init Initializer) validate() error {
+	switch init.Name {
+	case InitializerNameHeNormal, InitializerNameHeUniform, InitializerNameXavierNormal, InitializerNameXavierUniform,
+		InitializerNameLeCunNormal, InitializerNameLeCunUniform, InitializerNameOrthogonal, InitializerNameZeros,
+		InitializerNameConstant:
+		return nil
+	default:
+		return fmt.Errorf("unknown initializer '%s'", init.Name)
+	}
+}
+
+func (init Initializer) String() string {
+	if init.Name == InitializerNameConstant {
+		return fmt.Sprintf("%s (%.5g)", init.Name, init.Value)
+	}
+	return string(init.Name)
+}
+
+// weights generates the initial weights of a layer with the given number of neurons (fan out) and inputs (fan in)
+func (init Initializer) weights(rng *rand.Rand, neurons, inputs uint) mx.Matrix {
+	fanIn, fanAvg := float64(inputs), float64(inputs+neurons)/2
+	switch init.Name {
+	case InitializerNameHeNormal:
+		return mx.NewRandomNormalMatrixFrom(rng, neurons, inputs, math.Sqrt(2/fanIn))
+	case InitializerNameHeUniform:
+		return mx.NewRandomUniformMatrixFrom(rng, neurons, inputs, math.Sqrt(6/fanIn))
+	case InitializerNameXavierNormal:
+		return mx.NewRandomNormalMatrixFrom(rng, neurons, inputs, math.Sqrt(1/fanAvg))
+	case InitializerNameXavierUniform:
+		return mx.NewRandomUniformMatrixFrom(rng, neurons, inputs, math.Sqrt(3/fanAvg))
+	case InitializerNameLeCunNormal:
+		return mx.NewRandomNormalMatrixFrom(rng, neurons, inputs, math.Sqrt(1/fanIn))
+	case InitializerNameLeCunUniform:
+		return mx.NewRandomUniformMatrixFrom(rng, neurons, inputs, math.Sqrt(3/fanIn))
+	case InitializerNameOrthogonal:
+		return mx.NewRandomOrthogonalMatrixFrom(rng, neurons, inputs, 1)
+	case InitializerNameConstant:
+		return mx.NewConstantMatrix(neurons, inputs, init.Value)
+	default:
+		return mx.NewZeroMatrix(neurons, inputs)
+	}
+}
@@ -0,0 +1,232 @@
+package neuralnet
+
+import (
+	"errors"
+	"fmt"
+	"math"
+
+	"github.com/codehex/neuralnet/mx"
+)
+
+type LossName string
+
+const (
+	LossNameBinaryCrossEntropy         LossName = "binary-cross-entropy"
+	LossNameWeightedBinaryCrossEntropy LossName = "weighted-binary-cross-entropy"
+	LossNameCategoricalCrossEntropy    LossName = "categorical-cross-entropy"
+	LossNameFocal                      LossName = "focal"
+	LossNameHinge                      LossName = "hinge"
+	LossNameMeanSquaredError           LossName = "mean-squared-error"
+	LossNameMeanAbsoluteError          LossName = "mean-absolute-error"
+	LossNameHuber                      LossName = "huber"
+)
+
+// Loss chooses the cost function minimized by the training. By default binary cross-entropy is used with a sigmoid
+// output layer and categorical cross-entropy with a softmax output layer.
+type Loss struct {
+	Name LossName
+	// PositiveWeight scales the cost of positive examples for weighted binary cross-entropy
+	PositiveWeight float64
+	// Gamma focuses the focal loss on hard examples (0 is the same as binary cross-entropy) and Alpha, if not 0,
+	// weights the positive examples by Alpha and the negative ones by 1 - Alpha
+	Gamma float64
+	Alpha float64
+	// Delta is the error at which the Huber loss changes from quadratic to linear
+	Delta float64
+}
+
+// defaultLoss returns the cross-entropy loss matching the output activation function
+func defaultLoss(output ActivationFuncName) Loss {
+	if output == ActivationFuncNameSoftmax {
+		return Loss{Name: LossNameCategoricalCrossEntropy}
+	}
+	return Loss{Name: LossNameBinaryCrossEntropy}
+}
+
+// validate checks the loss can be used with the output layer
+func (l Loss) validate(output layerDefinition) error {
+	switch l.Name {
+	case LossNameBinaryCrossEntropy, LossNameWeightedBinaryCrossEntropy, LossNameFocal:
+		if output.actFuncLabel != ActivationFuncNameSigmoid {
+			return fmt.Errorf("%s loss requires a last layer with sigmoid activation function", l.Name)
+		}
+		if l.Name == LossNameWeightedBinaryCrossEntropy && l.PositiveWeight <= 0 {
+			return errors.New("weighted binary cross-entropy positive weight must be greater than 0")
+		}
+		if l.Name == LossNameFocal && (l.Gamma < 0 || l.Alpha < 0 || l.Alpha >= 1) {
+			return errors.New("focal loss gamma cannot be negative and alpha must be between 0 and 1")
+		}
+	case LossNameCategoricalCrossEntropy:
+		if output.actFuncLabel != ActivationFuncNameSoftmax {
+			return fmt.Errorf("%s loss requires a last layer with softmax activation function", l.Name)
+		}
+	case LossNameHinge:
+		if output.actFuncLabel == ActivationFuncNameSigmoid || output.actFuncLabel == ActivationFuncNameSoftmax {
+			return errors.New("hinge loss requires a last layer with an unbounded activation function, e.g. linear or tanh")
+		}
+	case LossNameMeanSquaredError, LossNameMeanAbsoluteError:
+	case LossNameHuber:
+		if l.Delta <= 0 {
+			return errors.New("huber loss delta must be greater than 0")
+		}
+	default:
+		return fmt.Errorf("unknown loss '%s'", l.Name)
+	}
+
+	switch output.actFuncLabel {
+	case ActivationFuncNameSigmoid:
+		// Several sigmoid neurons are used for multi-label classification, with a neuron per class
+	case ActivationFuncNameSoftmax:
+		if output.neurons < 3 {
+			return errors.New("last layer with softmax activation function must have at least 3 neurons, use a single sigmoid neuron for 2 classes")
+		}
+	default:
+		if output.neurons != 1 {
+			return fmt.Errorf("last layer with %s activation function must have 1 neuron", output.actFuncLabel)
+		}
+	}
+	return nil
+}
+
+func (l Loss) String() string {
+	switch l.Name {
+	case LossNameWeightedBinaryCrossEntropy:
+		return fmt.Sprintf("%s (positive weight %.5g)", l.Name, l.PositiveWeight)
+	case LossNameFocal:
+		return fmt.Sprintf("%s (gamma %.5g, alpha %.5g)", l.Name, l.Gamma, l.Alpha)
+	case LossNameHuber:
+		return fmt.Sprintf("%s (delta %.5g)", l.Name, l.Delta)
+	default:
+		return string(l.Name)
+	}
+}
+
+// fused returns true if the derivative of the loss and the output activation function simplify to dZ = A - Y,
+// which avoids dividing by activations close to 0 or 1
+func (l Loss) fused(output ActivationFuncName) bool {
+	return (l.Name == LossNameBinaryCrossEntropy && output == ActivationFuncNameSigmoid) ||
+		(l.Name == LossNameCategoricalCrossEntropy && output == ActivationFuncNameSoftmax)
+}
+
+// regression returns true if the loss can be used with continuous targets
+func (l Loss) regression() bool {
+	return l.Name == LossNameMeanSquaredError || l.Name == LossNameMeanAbsoluteError || l.Name == LossNameHuber
+}
+
+// value returns the average loss over the examples (columns) of the output A, with labels Y
+func (l Loss) value(A, Y mx.MatrixViewable) float64 {
+	rows, m := Y.Dims()
+	sum := 0.0
+	for j := 0; j < m; j++ {
+		for i := 0; i < rows; i++ {
+			sum += l.cost(A.At(i, j), Y.At(i, j))
+		}
+	}
+	return sum / float64(m)
+}
+
+// cost returns the loss of a single output a, with label y
+func (l Loss) cost(a, y float64) float64 {
+	switch l.Name {
+	case LossNameBinaryCrossEntropy:
+		return -(y * safeLog(a)) - ((1 - y) * safeLog(1-a))
+	case LossNameWeightedBinaryCrossEntropy:
+		return -(l.PositiveWeight * y * safeLog(a)) - ((1 - y) * safeLog(1-a))
+	case LossNameCategoricalCrossEntropy:
+		return -y * safeLog(a)
+	case LossNameFocal:
+		p, alpha := l.focalTarget(a, y)
+		return -alpha * math.Pow(1-p, l.Gamma) * safeLog(p)
+	case LossNameHinge:
+		return math.Max(0, 1-(2*y-1)*a)
+	case LossNameMeanSquaredError:
+		return (a - y) * (a - y)
+	case LossNameMeanAbsoluteError:
+		return math.Abs(a - y)
+	case LossNameHuber:
+		if d := math.Abs(a - y); d > l.Delta {
+			return l.Delta * (d - l.Delta/2)
+		}
+		return (a - y) * (a - y) / 2
+	default:
+		return 0
+	}
+}
+
+// gradient returns the derivative of the loss with respect to the output a, with label y
+func (l Loss) gradient(a, y float64) float64 {
+	switch l.Name {
+	case LossNameBinaryCrossEntropy:
+		a = clip(a)
+		return (-y / a) + ((1 - y) / (1 - a))
+	case LossNameWeightedBinaryCrossEntropy:
+		a = clip(a)
+		return (-l.PositiveWeight * y / a) + ((1 - y) / (1 - a))
+	case LossNameCategoricalCrossEntropy:
+		return -y / clip(a)
+	case LossNameFocal:
+		// Differentiate with respect to the probability of the true class, which moves opposite to a for negatives
+		p, alpha := l.focalTarget(a, y)
+		dp := alpha * (l.Gamma*math.Pow(1-p, l.Gamma-1)*safeLog(p) - math.Pow(1-p, l.Gamma)/p)
+		if y < 0.5 {
+			return -dp
+		}
+		return dp
+	case LossNameHinge:
+		t := 2*y - 1
+		if t*a < 1 {
+			return -t
+		}
+		return 0
+	case LossNameMeanSquaredError:
+		return 2 * (a - y)
+	case LossNameMeanAbsoluteError:
+		return sign(a - y)
+	case LossNameHuber:
+		if d := a - y; math.Abs(d) > l.Delta {
+			return l.Delta * sign(d)
+		}
+		return a - y
+	default:
+		return 0
+	}
+}
+
+// focalTarget returns the probability the output gives to the true class, and the weight of the class
+func (l Loss) focalTarget(a, y float64) (float64, float64) {
+	a = clip(a)
+	alpha := 1.0
+	if y >= 0.5 {
+		if l.Alpha != 0 {
+			alpha = l.Alpha
+		}
+		return a, alpha
+	}
+	if l.Alpha != 0 {
+		alpha = 1 - l.Alpha
+	}
+	return 1 - a, alpha
+}
+
+// logEpsilon keeps the cross-entropy finite when an activation saturates to exactly 0 or 1
+const logEpsilon = 1e-15
+
+func safeLog(x float64) float64 {
+	return math.Log(math.Max(x, logEpsilon))
+}
+
+// clip keeps a probability away from exactly 0 and 1
+func clip(a float64) float64 {
+	return math.Min(math.Max(a, logEpsilon), 1-logEpsilon)
+}
+
+func sign(v float64) float64 {
+	switch {
+	case v > 0:
+		return 1
+	case v < 0:
+		return -1
+	default:
+		return 0
+	}
+}
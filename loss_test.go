@@ -0,0 +1,116 @@
+package neuralnet_test
+
+import (
+	"math"
+	"testing"
+
+	"github.com/codehex/neuralnet"
+)
+
+func TestTrainModelWithLosses(t *testing.T) {
+	set := testImageSet(t)
+	builder := neuralnet.NewHyperParametersBuilder().
+		AddLayers(neuralnet.ActivationFuncNameReLU, 4).
+		SetLearningRate(0.05).
+		SetIterations(100).
+		SetSeed(1)
+	sigmoid := builder.AddLayers(neuralnet.ActivationFuncNameSigmoid, 1)
+	linear := builder.AddLayers(neuralnet.ActivationFuncNameLinear, 1)
+
+	tests := map[string]neuralnet.HyperParametersBuilder{
+		"binary cross-entropy": sigmoid.SetLoss(neuralnet.Loss{Name: neuralnet.LossNameBinaryCrossEntropy}),
+		"weighted binary cross-entropy": sigmoid.SetLoss(neuralnet.Loss{
+			Name: neuralnet.LossNameWeightedBinaryCrossEntropy, PositiveWeight: 2,
+		}),
+		"focal":               sigmoid.SetLoss(neuralnet.Loss{Name: neuralnet.LossNameFocal, Gamma: 2, Alpha: 0.25}),
+		"hinge":               linear.SetLoss(neuralnet.Loss{Name: neuralnet.LossNameHinge}),
+		"mean squared error":  linear.SetLoss(neuralnet.Loss{Name: neuralnet.LossNameMeanSquaredError}),
+		"mean absolute error": linear.SetLoss(neuralnet.Loss{Name: neuralnet.LossNameMeanAbsoluteError}),
+		"huber":               linear.SetLoss(neuralnet.Loss{Name: neuralnet.LossNameHuber, Delta: 1}),
+		"sigmoid mean squared error": sigmoid.SetLearningRate(0.5).
+			SetLoss(neuralnet.Loss{Name: neuralnet.LossNameMeanSquaredError}),
+	}
+	for name, b := range tests {
+		t.Run(name, func(t *testing.T) {
+			hyperParams, err := b.Build()
+			if err != nil {
+				t.Fatal(err)
+			}
+			model, err := hyperParams.TrainModel(set, nil)
+			if err != nil {
+				t.Fatal(err)
+			}
+			epochs := model.History().Epochs
+			first, last := epochs[0].Loss, epochs[len(epochs)-1].Loss
+			if math.IsNaN(last) || math.IsInf(last, 0) || last >= first {
+				t.Errorf("Expected the loss to decrease, but it went from %v to %v", first, last)
+			}
+			eval, err := model.Evaluate(set)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if eval.Accuracy < 0.9 {
+				t.Errorf("Expected the model to separate the training set, but got %v", eval)
+			}
+		})
+	}
+}
+
+func TestBuildInvalidLoss(t *testing.T) {
+	tests := map[string]neuralnet.HyperParametersBuilder{
+		"linear output with default loss": neuralnet.NewHyperParametersBuilder().
+			AddLayers(neuralnet.ActivationFuncNameLinear, 1),
+		"categorical cross-entropy with sigmoid": neuralnet.NewHyperParametersBuilder().
+			AddLayers(neuralnet.ActivationFuncNameSigmoid, 1).
+			SetLoss(neuralnet.Loss{Name: neuralnet.LossNameCategoricalCrossEntropy}),
+		"hinge with sigmoid": neuralnet.NewHyperParametersBuilder().
+			AddLayers(neuralnet.ActivationFuncNameSigmoid, 1).
+			SetLoss(neuralnet.Loss{Name: neuralnet.LossNameHinge}),
+		"weighted binary cross-entropy without weight": neuralnet.NewHyperParametersBuilder().
+			AddLayers(neuralnet.ActivationFuncNameSigmoid, 1).
+			SetLoss(neuralnet.Loss{Name: neuralnet.LossNameWeightedBinaryCrossEntropy}),
+		"huber without delta": neuralnet.NewHyperParametersBuilder().
+			AddLayers(neuralnet.ActivationFuncNameLinear, 1).
+			SetLoss(neuralnet.Loss{Name: neuralnet.LossNameHuber}),
+		"unknown loss": neuralnet.NewHyperParametersBuilder().
+			AddLayers(neuralnet.ActivationFuncNameSigmoid, 1).
+			SetLoss(neuralnet.Loss{Name: "unknown"}),
+	}
+	for name, b := range tests {
+		t.Run(name, func(t *testing.T) {
+			if _, err := b.Build(); err == nil {
+				t.Errorf("Expected an error")
+			}
+		})
+	}
+}
+
+func TestCrossEntropyFiniteWhenSaturated(t *testing.T) {
+	// The features are large enough that the sigmoid output rounds to exactly 0 or 1, where the log of the
+	// cross-entropy is infinite unless it is clamped
+	set, err := neuralnet.NewClassificationFeatureSet([][]float64{{-100}, {100}, {-80}, {80}},
+		[]string{"negative", "positive", "positive", "negative"}, "negative", "positive")
+	if err != nil {
+		t.Fatal(err)
+	}
+	ones := neuralnet.Initializer{Name: neuralnet.InitializerNameConstant, Value: 1}
+	hyperParams, err := neuralnet.NewHyperParametersBuilder().
+		AddLayersWithInitializer(neuralnet.ActivationFuncNameSigmoid, ones, 1).
+		SetLearningRate(0.01).
+		SetIterations(3).
+		Build()
+	if err != nil {
+		t.Fatal(err)
+	}
+	model, err := hyperParams.TrainModel(set, set)
+	if err != nil {
+		t.Fatal(err)
+	}
+	for _, epoch := range model.History().Epochs {
+		if math.IsNaN(epoch.Loss) || math.IsInf(epoch.Loss, 0) ||
+			math.IsNaN(epoch.Validation.Loss) || math.IsInf(epoch.Validation.Loss, 0) {
+			t.Fatalf("Expected finite losses, but got %v and %v in iteration %d",
+				epoch.Loss, epoch.Validation.Loss, epoch.Iteration)
+		}
+	}
+}
@@ -1,40 +1,79 @@
 package neuralnet
 
 import (
-	"fmt"
-	"math"
+	"context"
+	"math/rand"
 
 	"github.com/codehex/neuralnet/mx"
 )
 
 type TrainedModel struct {
-	hyper  HyperParameters
-	params *parameters
+	hyper         HyperParameters
+	params        *parameters
+	classes       []string
+	kind          labelKind
+	preprocessing Preprocessing
+	history       *TrainingHistory
 }
 
 type parameters struct {
 	W, b []mx.Matrix
+	// gamma scales the outputs of batch normalized layers, whose biases shift them, and mean and variance are the
+	// running statistics used for predictions. They are empty for the other layers.
+	gamma, mean, variance []mx.Matrix
 }
 
-type velocity struct {
-	vW, vb []mx.Matrix
+// newParameters creates empty weights and biases for the given number of layers (including the input layer)
+func newParameters(layers int) parameters {
+	return parameters{
+		W:        make([]mx.Matrix, layers),
+		b:        make([]mx.Matrix, layers),
+		gamma:    make([]mx.Matrix, layers),
+		mean:     make([]mx.Matrix, layers),
+		variance: make([]mx.Matrix, layers),
+	}
+}
+
+// batchNormalized returns true if layer i has the parameters of batch normalization
+func (p *parameters) batchNormalized(i int) bool {
+	return i < len(p.gamma) && p.gamma[i] != (mx.Matrix{})
+}
+
+func (p *parameters) clone() *parameters {
+	clone := newParameters(len(p.W))
+	for i := 1; i < len(p.W); i++ {
+		clone.W[i] = p.W[i].Clone()
+		clone.b[i] = p.b[i].Clone()
+		if p.batchNormalized(i) {
+			clone.gamma[i] = p.gamma[i].Clone()
+			clone.mean[i] = p.mean[i].Clone()
+			clone.variance[i] = p.variance[i].Clone()
+		}
+	}
+	return &clone
 }
 
 type cacheLayer struct {
 	Z, A, D, DW, Db, DZ, DA mx.Matrix
+	// ZNorm holds Z normalized before it was scaled and shifted, for batch normalized layers
+	ZNorm, InvStdDev, DGamma mx.Matrix
 }
 
 type batch struct {
 	X, Y  mx.MatrixViewable
 	cache []cacheLayer
+	// x and y hold the examples of the batch when they are shuffled every epoch, with X and Y set to the same matrices
+	x, y mx.Matrix
 }
 
-func (h HyperParameters) partitionSamples(batchSize uint, nodes []uint, set *ImageSet) []batch {
-	if batchSize == 0 || batchSize > set.NumberOfExamples() {
+func (h HyperParameters) partitionSamples(batchSize uint, nodes []uint, set Dataset) []batch {
+	if batchSize == 0 || batchSize >= set.NumberOfExamples() {
 		return []batch{
 			{X: set.X(), Y: set.Y(), cache: h.initCache(nodes, set.NumberOfExamples())},
 		}
 	}
+	features, _ := set.X().Dims()
+	labels, _ := set.Y().Dims()
 
 	numOfBatches := set.NumberOfExamples() / batchSize
 	if set.NumberOfExamples()%batchSize != 0 {
@@ -47,77 +86,101 @@ func (h HyperParameters) partitionSamples(batchSize uint, nodes []uint, set *Ima
 		if end > set.NumberOfExamples() {
 			end = set.NumberOfExamples()
 		}
-		batches[i] = batch{
-			X:     set.X().SliceColumns(int(start), int(end)),
-			Y:     set.Y().SliceColumns(int(start), int(end)),
-			cache: h.initCache(nodes, end-start),
+		if h.noShuffle {
+			batches[i] = batch{
+				X:     set.X().SliceColumns(int(start), int(end)),
+				Y:     set.Y().SliceColumns(int(start), int(end)),
+				cache: h.initCache(nodes, end-start),
+			}
+			continue
 		}
+		// The examples are copied into the batch by shuffleBatches at the start of every epoch
+		x := mx.NewZeroMatrix(uint(features), end-start)
+		y := mx.NewZeroMatrix(uint(labels), end-start)
+		batches[i] = batch{X: x, Y: y, x: x, y: y, cache: h.initCache(nodes, end-start)}
 	}
 	return batches
 }
 
-func (h HyperParameters) TrainModel(trainingDataSet *ImageSet) (*TrainedModel, error) {
-	nodes := h.generateNodes(trainingDataSet.featureCount)
-	params := h.initParameters(nodes)
-	m := trainingDataSet.NumberOfExamples()
-	batches := h.partitionSamples(h.miniBatchSize, nodes, trainingDataSet)
-	L := len(nodes) - 1
-	v := h.initVelocity(nodes)
+// shuffleBatches copies the examples of the set into the mini-batches in a new random order, reusing the matrices
+// of the batches
+func shuffleBatches(batches []batch, set Dataset, rng *rand.Rand) {
+	order := rng.Perm(int(set.NumberOfExamples()))
+	start := 0
+	for _, b := range batches {
+		_, size := b.x.Dims()
+		b.x.CopyColumns(set.X(), order[start:start+size])
+		b.y.CopyColumns(set.Y(), order[start:start+size])
+		start += size
+	}
+}
 
-	for iter := uint(0); iter < h.iterations; iter++ {
-		for batchIndex, batch := range batches {
-			// Forward propagation
-			for i := 1; i < len(nodes); i++ {
-				h.forwardPropagation(batch.X, batch.cache, params, i, true)
-			}
+// TrainModel trains a model on the training set. The validation set is optional (nil to skip it), if given the
+// model is evaluated against it after every iteration, which is required for early stopping.
+func (h HyperParameters) TrainModel(trainingDataSet, validationDataSet Dataset) (*TrainedModel, error) {
+	return h.TrainModelContext(context.Background(), trainingDataSet, validationDataSet)
+}
 
-			// Set up the cache for the last layer
-			// dAL = - P1 + P2 where P1 = Y / AL and P2 = (1 - Y) / (1 - AL)
-			batch.cache[L].DA.MatrixElemOp(batch.Y, batch.cache[L].A, func(y, a float64) float64 {
-				return (-y / a) + ((1 - y) / (1 - a))
-			})
+// TrainModelContext trains a model like TrainModel, checking for cancellation of the context between mini-batches.
+// If the context is cancelled, the partially trained model is returned together with the context's error.
+func (h HyperParameters) TrainModelContext(ctx context.Context, trainingDataSet, validationDataSet Dataset) (*TrainedModel, error) {
+	t, err := h.newTrainer(trainingDataSet, validationDataSet)
+	if err != nil {
+		return nil, err
+	}
+	return t.run(ctx)
+}
 
-			// Print the cost every 100 iterations
-			if iter != 0 && iter%100 == 0 {
-				fmt.Println("iter:", iter, ", batch:", batchIndex, ", cost", h.costFunction(batch.cache[L].A, batch.Y, params.W[L]))
-			}
+// Classes returns the class labels the model predicts, where the position of each label is its class index
+func (t *TrainedModel) Classes() []string {
+	return t.classes
+}
 
-			// Backward propagation and update parameters
-			for i := L; i > 0; i-- {
-				h.backwardPropagation(batch.X, batch.cache, params, i, m)
-				h.updateParameters(batch.cache, params, v, i)
-			}
-		}
+// Regression returns true if the model predicts continuous values rather than classes
+func (t *TrainedModel) Regression() bool {
+	return t.kind == targetValues
+}
+
+// MultiLabel returns true if the model predicts any number of classes per example
+func (t *TrainedModel) MultiLabel() bool {
+	return t.kind == multipleLabels
+}
+
+// History returns the metrics recorded while training the model, or nil for a loaded model
+func (t *TrainedModel) History() *TrainingHistory {
+	return t.history
+}
+
+// Preprocessing returns the preprocessing applied to the images the model was trained with
+func (t *TrainedModel) Preprocessing() Preprocessing {
+	return t.preprocessing
+}
+
+// learningRateAt returns the learning rate for the given epoch and step (both counted from 0)
+func (h HyperParameters) learningRateAt(epoch, step, batchesPerEpoch uint) float64 {
+	total := h.iterations
+	if h.schedule.perBatch {
+		total *= batchesPerEpoch
 	}
-	return &TrainedModel{hyper: h, params: params}, nil
+	return h.schedule.learningRate(h.learningRate, int(epoch), int(step), int(total))
 }
 
-func (h HyperParameters) initParameters(nodes []uint) *parameters {
+func (h HyperParameters) initParameters(nodes []uint, rng *rand.Rand) *parameters {
 	// We store the weights and biases as indexed by layer number, so we store an empty matrics for
 	// layer 0 (the input layer)
-	params := parameters{
-		W: make([]mx.Matrix, len(nodes)),
-		b: make([]mx.Matrix, len(nodes)),
-	}
+	params := newParameters(len(nodes))
 	for i := 1; i < len(nodes); i++ {
-		params.W[i] = mx.NewRandomMatrix(nodes[i], nodes[i-1], h.Layer(i).initFactor(nodes[i-1]))
+		params.W[i] = h.Layer(i).initializer.weights(rng, nodes[i], nodes[i-1])
 		params.b[i] = mx.NewZeroMatrix(nodes[i], 1)
+		if h.Layer(i).batchNorm {
+			params.gamma[i] = mx.NewConstantMatrix(nodes[i], 1, 1)
+			params.mean[i] = mx.NewZeroMatrix(nodes[i], 1)
+			params.variance[i] = mx.NewConstantMatrix(nodes[i], 1, 1)
+		}
 	}
 	return &params
 }
 
-func (h HyperParameters) initVelocity(nodes []uint) *velocity {
-	vel := velocity{
-		vW: make([]mx.Matrix, len(nodes)),
-		vb: make([]mx.Matrix, len(nodes)),
-	}
-	for i := 1; i < len(nodes); i++ {
-		vel.vW[i] = mx.NewZeroMatrix(nodes[i], nodes[i-1])
-		vel.vb[i] = mx.NewZeroMatrix(nodes[i], 1)
-	}
-	return &vel
-}
-
 func (h HyperParameters) initCache(nodes []uint, numOfExamples uint) []cacheLayer {
 	cache := make([]cacheLayer, len(nodes))
 	// Initialize the caches by generating all Z, A and delta matrices, with A[0] being the training data set
@@ -130,24 +193,36 @@ func (h HyperParameters) initCache(nodes []uint, numOfExamples uint) []cacheLaye
 		cache[i].Db = mx.NewZeroMatrix(nodes[i], 1)
 		cache[i].DZ = mx.NewZeroMatrix(nodes[i], m)
 		cache[i].DA = mx.NewZeroMatrix(nodes[i], m)
+		if h.Layer(i).batchNorm {
+			cache[i].ZNorm = mx.NewZeroMatrix(nodes[i], m)
+			cache[i].InvStdDev = mx.NewZeroMatrix(nodes[i], 1)
+			cache[i].DGamma = mx.NewZeroMatrix(nodes[i], 1)
+		}
 	}
 	return cache
 }
 
-func (h HyperParameters) forwardPropagation(X mx.MatrixViewable, cache []cacheLayer, params *parameters, i int, training bool) {
+// forwardPropagation calculates the activations of layer i. Dropout and the batch statistics of batch normalization
+// are only used while training, which is indicated by passing the random generator used for the dropout masks, and
+// is nil otherwise.
+func (h HyperParameters) forwardPropagation(X mx.MatrixViewable, cache []cacheLayer, params *parameters, i int, rng *rand.Rand) {
 	if i == 1 {
 		cache[i].Z.MatrixMultiply(params.W[i], X)
 	} else {
 		cache[i].Z.MatrixMultiply(params.W[i], cache[i-1].A)
 	}
-	cache[i].Z.AddColumnVector(cache[i].Z, params.b[i])
-	cache[i].A.ElemOp(cache[i].Z, h.Layer(i).ActivationFunc())
+	if h.Layer(i).batchNorm {
+		h.batchNorm.normalize(cache[i], params, i, rng != nil)
+	} else {
+		cache[i].Z.AddColumnVector(cache[i].Z, params.b[i])
+	}
+	h.Layer(i).activate(cache[i].A, cache[i].Z)
 	// Only knock out neurons if we're not on the last layer
-	if h.keepProb != 0 && i != len(cache)-1 && training {
+	if h.keepProb != 0 && i != len(cache)-1 && rng != nil {
 		// Random generate a matrix with the same dimensions as A[i], set to either 0 or 1
 		// with probability keepProb
 		rows, cols := cache[i].A.Dims()
-		cache[i].D = mx.NewRandomUnitMatrix(uint(rows), uint(cols), h.keepProb)
+		cache[i].D = mx.NewRandomUnitMatrixFrom(rng, uint(rows), uint(cols), h.keepProb)
 		// Scale up existing values by 1/keepProb
 		cache[i].D.ElemOp(cache[i].D, func(v float64) float64 { return v / h.keepProb })
 		// Knock out some of the neurons, boosting the ones that are there
@@ -156,26 +231,29 @@ func (h HyperParameters) forwardPropagation(X mx.MatrixViewable, cache []cacheLa
 }
 
 func (h HyperParameters) costFunction(A, Y, W mx.MatrixViewable) float64 {
-	_, m := Y.Dims()
-	sum := float64(0)
-	for i := 0; i < m; i++ {
-		sum += (Y.At(0, i) * math.Log(A.At(0, i))) + ((1 - Y.At(0, i)) * math.Log(1-A.At(0, i)))
-	}
+	cost := h.loss.value(A, Y)
 	if h.regularizationFactor != 0 {
-		sum += (h.regularizationFactor / 2) * W.FrobeniusNorm()
+		_, m := Y.Dims()
+		cost += (h.regularizationFactor / 2) * W.FrobeniusNorm() / float64(m)
 	}
-	return -sum / float64(m)
+	return cost
 }
 
 func (h HyperParameters) backwardPropagation(X mx.MatrixViewable, cache []cacheLayer, params *parameters, i int, m uint) {
-	cache[i].DZ.ElemOp(cache[i].Z, h.Layer(i).ActivationDerivativeFunc())
-	cache[i].DZ.MatrixElemOp(cache[i].DZ, cache[i].DA, func(v1, v2 float64) float64 { return v1 * v2 })
+	// DZ for the last layer is calculated directly from the cost function when the loss is fused with the activation
+	if i != len(cache)-1 || !h.loss.fused(h.outputLayer().actFuncLabel) {
+		h.Layer(i).activationGradient(cache[i].DZ, cache[i].Z, cache[i].A, cache[i].DA)
+	}
+	if h.Layer(i).batchNorm {
+		h.batchNorm.normalizeBackward(cache[i], params, i)
+	} else {
+		cache[i].Db.RowSum(cache[i].DZ, true)
+	}
 	if i == 1 {
 		cache[i].DW.MatrixMultiply(cache[i].DZ, X.Transpose())
 	} else {
 		cache[i].DW.MatrixMultiply(cache[i].DZ, cache[i-1].A.Transpose())
 	}
-	cache[i].Db.RowSum(cache[i].DZ, true)
 	if i > 1 {
 		cache[i-1].DA.MatrixMultiply(params.W[i].Transpose(), cache[i].DZ)
 	}
@@ -189,54 +267,3 @@ func (h HyperParameters) backwardPropagation(X mx.MatrixViewable, cache []cacheL
 	}
 	cache[i].DW.ElemOp(cache[i].DW, func(v float64) float64 { return v / float64(m) })
 }
-
-func (h HyperParameters) updateParameters(cache []cacheLayer, params *parameters, v *velocity, i int) {
-	deltaFunc := func(x, dx float64) float64 { return x - h.learningRate*dx }
-	if h.momentumBeta == 0 {
-		params.W[i].MatrixElemOp(params.W[i], cache[i].DW, deltaFunc)
-		params.b[i].MatrixElemOp(params.b[i], cache[i].Db, deltaFunc)
-		return
-	}
-
-	// Update the momentum and use that to update the parameters
-	updateMomentFunc := func(v1, v2 float64) float64 {
-		return (h.momentumBeta * v1) + ((1 - h.momentumBeta) * v2)
-	}
-
-	v.vW[i].MatrixElemOp(v.vW[i], cache[i].DW, updateMomentFunc)
-	v.vb[i].MatrixElemOp(v.vb[i], cache[i].Db, updateMomentFunc)
-	params.W[i].MatrixElemOp(params.W[i], v.vW[i], deltaFunc)
-	params.b[i].MatrixElemOp(params.b[i], v.vb[i], deltaFunc)
-}
-
-func (t *TrainedModel) Predict(set *ImageSet) {
-	nodes := t.hyper.generateNodes(set.featureCount)
-	params := t.params
-	cache := t.hyper.initCache(nodes, set.NumberOfExamples())
-	L := len(nodes) - 1
-	Y := set.Y()
-	m := set.NumberOfExamples()
-
-	for i := 1; i < len(nodes); i++ {
-		t.hyper.forwardPropagation(set.X(), cache, params, i, false)
-	}
-
-	var correct uint
-	var incorrect uint
-	for i := 0; i < int(m); i++ {
-		var predicted float64
-		if cache[L].A.At(0, i) > 0.5 {
-			predicted = 1
-		} else {
-			predicted = 0
-		}
-		if predicted == Y.At(0, i) {
-			correct++
-		} else {
-			incorrect++
-		}
-	}
-
-	fmt.Println("correct:", correct, ", incorrect:", incorrect, ", accuracy:", float64(correct)/float64(m))
-
-}
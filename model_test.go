@@ -0,0 +1,399 @@
+package neuralnet_test
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"math"
+	"testing"
+	"time"
+
+	"github.com/codehex/neuralnet"
+)
+
+func TestTrainModelWithOptimizers(t *testing.T) {
+	set := testImageSet(t)
+	builder := neuralnet.NewHyperParametersBuilder().
+		AddLayers(neuralnet.ActivationFuncNameReLU, 4).
+		AddLayers(neuralnet.ActivationFuncNameSigmoid, 1).
+		SetIterations(100)
+
+	tests := map[string]neuralnet.HyperParametersBuilder{
+		"gradient descent": builder.SetLearningRate(0.1),
+		"momentum":         builder.SetLearningRate(0.1).UseGradientDescentWithMomentum(0.9),
+		"nesterov":         builder.SetLearningRate(0.1).UseNesterovMomentum(0.9),
+		"rmsprop":          builder.UseRMSProp(0.9, 1e-8),
+		"adagrad":          builder.SetLearningRate(0.1).UseAdaGrad(1e-8),
+		"adam":             builder.UseAdam(0.9, 0.999, 1e-8),
+		"adamw":            builder.UseAdamW(0.9, 0.999, 1e-8, 0.01),
+	}
+	for name, b := range tests {
+		t.Run(name, func(t *testing.T) {
+			hyperParams, err := b.Build()
+			if err != nil {
+				t.Fatal(err)
+			}
+			model, err := hyperParams.TrainModel(set, nil)
+			if err != nil {
+				t.Fatal(err)
+			}
+			eval, err := model.Evaluate(set)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if eval.Accuracy < 0.9 {
+				t.Errorf("Expected the model to separate the training set, but got %v", eval)
+			}
+		})
+	}
+}
+
+func TestBuildInvalidOptimizer(t *testing.T) {
+	_, err := neuralnet.NewHyperParametersBuilder().
+		AddLayers(neuralnet.ActivationFuncNameSigmoid, 1).
+		UseAdam(1.5, 0.999, 1e-8).
+		Build()
+	if err == nil {
+		t.Errorf("Expected an error for an Adam beta greater than 1")
+	}
+}
+
+func TestTrainModelWithLearningRateSchedule(t *testing.T) {
+	set := testImageSet(t)
+	var epochs, steps []int
+	hyperParams, err := neuralnet.NewHyperParametersBuilder().
+		AddLayers(neuralnet.ActivationFuncNameReLU, 4).
+		AddLayers(neuralnet.ActivationFuncNameSigmoid, 1).
+		SetIterations(3).
+		SetMiniBatchSize(8).
+		UseLearningRateSchedule(func(epoch, step int) float64 {
+			epochs = append(epochs, epoch)
+			steps = append(steps, step)
+			return 0.01
+		}).
+		Build()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, err := hyperParams.TrainModel(set, nil); err != nil {
+		t.Fatal(err)
+	}
+
+	// 20 examples in batches of 8 gives 3 batches per epoch
+	if len(steps) != 9 {
+		t.Fatalf("Expected the schedule to be evaluated for 9 batches, but got %d", len(steps))
+	}
+	for i := range steps {
+		if steps[i] != i || epochs[i] != i/3 {
+			t.Errorf("Expected call %d to be for epoch %d step %d, but got epoch %d step %d", i, i/3, i, epochs[i], steps[i])
+		}
+	}
+}
+
+func TestBuildInvalidLearningRateSchedule(t *testing.T) {
+	_, err := neuralnet.NewHyperParametersBuilder().
+		AddLayers(neuralnet.ActivationFuncNameSigmoid, 1).
+		UseStepDecay(0.5, 0).
+		Build()
+	if err == nil {
+		t.Errorf("Expected an error for a step decay interval of 0")
+	}
+}
+
+func TestTrainModelEarlyStopping(t *testing.T) {
+	set := testImageSet(t)
+	const iterations = 5000
+	builder := neuralnet.NewHyperParametersBuilder().
+		AddLayers(neuralnet.ActivationFuncNameReLU, 4).
+		AddLayers(neuralnet.ActivationFuncNameSigmoid, 1).
+		SetLearningRate(0.1).
+		SetIterations(iterations).
+		SetSeed(1).
+		UseEarlyStopping(5, 1e-3)
+
+	hyperParams, err := builder.Build()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, err := hyperParams.TrainModel(set, nil); err == nil {
+		t.Errorf("Expected an error when early stopping without a validation set")
+	}
+
+	// The loss of the validation set stops improving by more than the minimum delta long before all the iterations
+	model, err := hyperParams.TrainModel(set, set)
+	if err != nil {
+		t.Fatal(err)
+	}
+	history := model.History()
+	if !history.StoppedEarly || len(history.Epochs) >= iterations {
+		t.Fatalf("Expected the training to stop early, but it ran %d of %d iterations", len(history.Epochs), iterations)
+	}
+	if history.BestIteration >= uint(len(history.Epochs)) {
+		t.Fatalf("Expected the best iteration to be one of the %d run, but got %d", len(history.Epochs),
+			history.BestIteration)
+	}
+	best := history.Epochs[history.BestIteration].Validation.Loss
+	for _, epoch := range history.Epochs {
+		if epoch.Validation.Loss < best-1e-3 {
+			t.Errorf("Expected the best validation loss %v, but iteration %d has %v", best, epoch.Iteration,
+				epoch.Validation.Loss)
+		}
+	}
+
+	// The model has the parameters of the best iteration rather than the last one
+	eval, err := model.Evaluate(set)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if math.Abs(eval.Loss-best) > 1e-9 {
+		t.Errorf("Expected the restored model to have the best validation loss %v, but got %v", best, eval.Loss)
+	}
+	if eval.Accuracy < 0.9 {
+		t.Errorf("Expected the best model to separate the training set, but got %v", eval)
+	}
+}
+
+func TestTrainModelCallbacksAndHistory(t *testing.T) {
+	set := testImageSet(t)
+	var started, ended bool
+	var batches, epochs int
+	hyperParams, err := neuralnet.NewHyperParametersBuilder().
+		AddLayers(neuralnet.ActivationFuncNameReLU, 4).
+		AddLayers(neuralnet.ActivationFuncNameSigmoid, 1).
+		SetIterations(5).
+		SetMiniBatchSize(10).
+		AddCallbacks(neuralnet.CallbackFuncs{
+			TrainStart: func(event neuralnet.TrainStartEvent) { started = true },
+			BatchEnd:   func(event neuralnet.BatchEndEvent) { batches++ },
+			EpochEnd: func(event neuralnet.EpochEndEvent) {
+				epochs++
+				if event.Metrics.Validation == nil {
+					t.Errorf("Expected the validation set to be evaluated at iteration %d", event.Iteration)
+				}
+			},
+			TrainEnd: func(event neuralnet.TrainEndEvent) { ended = true },
+		}).
+		Build()
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	model, err := hyperParams.TrainModel(set, set)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !started || !ended || batches != 10 || epochs != 5 {
+		t.Errorf("Expected start, 10 batches, 5 epochs and end events, but got %t, %d, %d, %t", started, batches, epochs, ended)
+	}
+
+	history := model.History()
+	if len(history.Epochs) != 5 {
+		t.Fatalf("Expected 5 epochs in the history, but got %d", len(history.Epochs))
+	}
+	for i, epoch := range history.Epochs {
+		if epoch.Iteration != uint(i) || epoch.Loss <= 0 {
+			t.Errorf("Expected iteration %d to have a positive loss, but got %+v", i, epoch)
+		}
+	}
+}
+
+func TestTrainModelContextCancelled(t *testing.T) {
+	set := testImageSet(t)
+	ctx, cancel := context.WithCancel(context.Background())
+	hyperParams, err := neuralnet.NewHyperParametersBuilder().
+		AddLayers(neuralnet.ActivationFuncNameReLU, 4).
+		AddLayers(neuralnet.ActivationFuncNameSigmoid, 1).
+		SetIterations(1000000).
+		AddCallbacks(neuralnet.CallbackFuncs{
+			EpochEnd: func(event neuralnet.EpochEndEvent) {
+				if event.Iteration == 2 {
+					cancel()
+				}
+			},
+		}).
+		Build()
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	model, err := hyperParams.TrainModelContext(ctx, set, nil)
+	if !errors.Is(err, context.Canceled) {
+		t.Errorf("Expected a cancelled error, but got %v", err)
+	}
+	if model == nil || len(model.History().Epochs) != 3 {
+		t.Fatalf("Expected the partially trained model to be returned after 3 iterations")
+	}
+	if _, err := model.Evaluate(set); err != nil {
+		t.Errorf("Expected the partially trained model to be usable, but got %v", err)
+	}
+}
+
+func TestTrainModelTimeBudget(t *testing.T) {
+	set := testImageSet(t)
+	hyperParams, err := neuralnet.NewHyperParametersBuilder().
+		AddLayers(neuralnet.ActivationFuncNameReLU, 4).
+		AddLayers(neuralnet.ActivationFuncNameSigmoid, 1).
+		SetIterations(1000000000).
+		SetTimeBudget(50 * time.Millisecond).
+		Build()
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	model, err := hyperParams.TrainModel(set, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !model.History().BudgetExhausted {
+		t.Errorf("Expected the training to end by exhausting the time budget")
+	}
+}
+
+func TestTrainModelWithSeedIsReproducible(t *testing.T) {
+	set := testImageSet(t)
+	train := func(seed int64) []byte {
+		hyperParams, err := neuralnet.NewHyperParametersBuilder().
+			AddLayers(neuralnet.ActivationFuncNameReLU, 4, 4).
+			AddLayers(neuralnet.ActivationFuncNameSigmoid, 1).
+			SetIterations(20).
+			SetDropoutKeepProbability(0.8).
+			SetMiniBatchSize(8).
+			SetSeed(seed).
+			Build()
+		if err != nil {
+			t.Fatal(err)
+		}
+		model, err := hyperParams.TrainModel(set, nil)
+		if err != nil {
+			t.Fatal(err)
+		}
+		var saved bytes.Buffer
+		if err := model.Save(&saved); err != nil {
+			t.Fatal(err)
+		}
+		return saved.Bytes()
+	}
+
+	if !bytes.Equal(train(42), train(42)) {
+		t.Errorf("Expected two runs with the same seed to produce identical models")
+	}
+	if bytes.Equal(train(42), train(43)) {
+		t.Errorf("Expected runs with different seeds to produce different models")
+	}
+}
+
+func TestTrainModelWithInitializers(t *testing.T) {
+	set := testImageSet(t)
+	initializers := []neuralnet.Initializer{
+		{Name: neuralnet.InitializerNameHeNormal},
+		{Name: neuralnet.InitializerNameHeUniform},
+		{Name: neuralnet.InitializerNameXavierNormal},
+		{Name: neuralnet.InitializerNameXavierUniform},
+		{Name: neuralnet.InitializerNameLeCunNormal},
+		{Name: neuralnet.InitializerNameLeCunUniform},
+		{Name: neuralnet.InitializerNameOrthogonal},
+		{Name: neuralnet.InitializerNameZeros},
+		{Name: neuralnet.InitializerNameConstant, Value: 0.01},
+	}
+	for _, init := range initializers {
+		t.Run(string(init.Name), func(t *testing.T) {
+			hyperParams, err := neuralnet.NewHyperParametersBuilder().
+				AddLayersWithInitializer(neuralnet.ActivationFuncNameReLU, init, 4).
+				AddLayersWithInitializer(neuralnet.ActivationFuncNameSigmoid, init, 1).
+				SetIterations(10).
+				SetSeed(1).
+				Build()
+			if err != nil {
+				t.Fatal(err)
+			}
+			model, err := hyperParams.TrainModel(set, nil)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if loss := model.History().Epochs[9].Loss; math.IsNaN(loss) || math.IsInf(loss, 0) {
+				t.Errorf("Expected a finite loss, but got %v", loss)
+			}
+		})
+	}
+
+	_, err := neuralnet.NewHyperParametersBuilder().
+		AddLayersWithInitializer(neuralnet.ActivationFuncNameSigmoid, neuralnet.Initializer{Name: "unknown"}, 1).
+		Build()
+	if err == nil {
+		t.Errorf("Expected an error for an unknown initializer")
+	}
+}
+
+func TestTrainModelShufflesMiniBatches(t *testing.T) {
+	set := testImageSet(t)
+	builder := neuralnet.NewHyperParametersBuilder().
+		AddLayers(neuralnet.ActivationFuncNameReLU, 4).
+		AddLayers(neuralnet.ActivationFuncNameSigmoid, 1).
+		SetLearningRate(0.1).
+		SetIterations(50).
+		SetMiniBatchSize(5).
+		SetSeed(1)
+	batchLosses := func(b neuralnet.HyperParametersBuilder) []float64 {
+		var losses []float64
+		hyperParams, err := b.AddCallbacks(neuralnet.CallbackFuncs{
+			BatchEnd: func(event neuralnet.BatchEndEvent) { losses = append(losses, event.Loss) },
+		}).Build()
+		if err != nil {
+			t.Fatal(err)
+		}
+		model, err := hyperParams.TrainModel(set, nil)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if eval, err := model.Evaluate(set); err != nil || eval.Accuracy < 0.9 {
+			t.Errorf("Expected the model to separate the training set, but got %v (%v)", eval, err)
+		}
+		return losses
+	}
+
+	shuffled, fixed := batchLosses(builder), batchLosses(builder.WithoutShuffling())
+	if len(shuffled) != 200 || len(fixed) != 200 {
+		t.Fatalf("Expected 4 batches for 50 iterations, but got %d and %d", len(shuffled), len(fixed))
+	}
+	same := true
+	for i := range shuffled {
+		same = same && shuffled[i] == fixed[i]
+	}
+	if same {
+		t.Errorf("Expected shuffled mini-batches to train differently to fixed ones")
+	}
+}
+
+func TestTrainModelMiniBatchSizeOfWholeSet(t *testing.T) {
+	set := testImageSet(t)
+	builder := neuralnet.NewHyperParametersBuilder().
+		AddLayers(neuralnet.ActivationFuncNameReLU, 4).
+		AddLayers(neuralnet.ActivationFuncNameSigmoid, 1).
+		SetLearningRate(0.1).
+		SetIterations(50).
+		SetSeed(1)
+	train := func(b neuralnet.HyperParametersBuilder) *neuralnet.TrainedModel {
+		hyperParams, err := b.Build()
+		if err != nil {
+			t.Fatal(err)
+		}
+		model, err := hyperParams.TrainModel(set, nil)
+		if err != nil {
+			t.Fatal(err)
+		}
+		return model
+	}
+
+	// A mini-batch holding the whole set trains exactly like no mini-batches
+	whole := train(builder.SetMiniBatchSize(set.NumberOfExamples()))
+	full := train(builder)
+	if eval, err := whole.Evaluate(set); err != nil || eval.Accuracy < 0.9 {
+		t.Errorf("Expected the model to separate the training set, but got %v (%v)", eval, err)
+	}
+	wholeEpochs, fullEpochs := whole.History().Epochs, full.History().Epochs
+	for i := range fullEpochs {
+		if wholeEpochs[i].Loss != fullEpochs[i].Loss {
+			t.Fatalf("Expected the loss of iteration %d to be %v, but got %v", i, fullEpochs[i].Loss, wholeEpochs[i].Loss)
+		}
+	}
+}
@@ -0,0 +1,78 @@
+package neuralnet_test
+
+import (
+	"image/color"
+	"testing"
+
+	"github.com/codehex/neuralnet"
+)
+
+func TestTrainMultiLabelModel(t *testing.T) {
+	dir := t.TempDir()
+	writeTestImages(t, dir, "red", 10, color.RGBA{200, 20, 20, 255})
+	writeTestImages(t, dir, "blue", 10, color.RGBA{20, 20, 200, 255})
+	writeTestImages(t, dir, "purple", 10, color.RGBA{200, 20, 200, 255})
+	writeTestImages(t, dir, "black", 10, color.RGBA{20, 20, 20, 255})
+	set, err := neuralnet.NewImageSetBuilder().
+		WithPathPrefix(dir).
+		WithClasses("red", "blue").
+		AddFolderWithLabels("red", "red").
+		AddFolderWithLabels("blue", "blue").
+		AddFolderWithLabels("purple", "red", "blue").
+		AddFolderWithLabels("black").
+		Normalize().
+		Build()
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	hyperParams, err := neuralnet.NewHyperParametersBuilder().
+		AddLayers(neuralnet.ActivationFuncNameReLU, 8).
+		AddLayers(neuralnet.ActivationFuncNameSigmoid, 2).
+		UseAdam(0.9, 0.999, 1e-8).
+		SetIterations(200).
+		SetSeed(1).
+		Build()
+	if err != nil {
+		t.Fatal(err)
+	}
+	model, err := hyperParams.TrainModel(set, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	predictions, err := model.Predict(set)
+	if err != nil {
+		t.Fatal(err)
+	}
+	// The purple images come after 10 red and 10 blue images
+	if labels := predictions[20].Labels; len(labels) != 2 || labels[0] != "red" || labels[1] != "blue" {
+		t.Errorf("Expected a purple image to be labelled [red blue], but got %v", labels)
+	}
+	if labels := predictions[30].Labels; len(labels) != 0 {
+		t.Errorf("Expected a black image to have no labels, but got %v", labels)
+	}
+
+	eval, err := model.Evaluate(set)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if eval.Accuracy < 0.9 || eval.HammingLoss > 0.05 {
+		t.Errorf("Expected the model to separate the training set, but got %v", eval)
+	}
+	if len(eval.Labels) != 2 || eval.Labels[0].Label != "red" || eval.Labels[0].Precision < 0.9 || eval.Labels[0].Recall < 0.9 {
+		t.Errorf("Expected precision and recall of each label, but got %+v", eval.Labels)
+	}
+}
+
+func TestTrainSeveralSigmoidOutputsWithoutMultiLabelSet(t *testing.T) {
+	hyperParams, err := neuralnet.NewHyperParametersBuilder().
+		AddLayers(neuralnet.ActivationFuncNameSigmoid, 2).
+		Build()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, err := hyperParams.TrainModel(testImageSet(t), nil); err == nil {
+		t.Errorf("Expected an error when training several sigmoid outputs on single class labels")
+	}
+}
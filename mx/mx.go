@@ -2,6 +2,7 @@ package mx
 
 import (
 	"fmt"
+	"math"
 	"math/rand"
 	"sync"
 	"time"
@@ -62,9 +63,12 @@ func (m Matrix) Set(row, column int, value float64) {
 }
 
 func NewRandomMatrix(rows, columns uint, randomFactor float64) Matrix {
-	source := rand.NewSource(time.Now().UnixNano())
-	r := rand.New(source)
+	return NewRandomMatrixFrom(newTimeSeededRand(), rows, columns, randomFactor)
+}
 
+// NewRandomMatrixFrom creates a matrix of values drawn uniformly from [0, randomFactor) using the given
+// random generator, so the values can be reproduced by seeding it
+func NewRandomMatrixFrom(r *rand.Rand, rows, columns uint, randomFactor float64) Matrix {
 	result := make([]float64, rows*columns)
 	for i := range result {
 		result[i] = r.Float64() * randomFactor
@@ -73,9 +77,12 @@ func NewRandomMatrix(rows, columns uint, randomFactor float64) Matrix {
 }
 
 func NewRandomUnitMatrix(rows, columns uint, prob float64) Matrix {
-	source := rand.NewSource(time.Now().UnixNano())
-	r := rand.New(source)
+	return NewRandomUnitMatrixFrom(newTimeSeededRand(), rows, columns, prob)
+}
 
+// NewRandomUnitMatrixFrom creates a matrix where each value is 1 with probability prob and 0 otherwise, using the
+// given random generator
+func NewRandomUnitMatrixFrom(r *rand.Rand, rows, columns uint, prob float64) Matrix {
 	result := make([]float64, rows*columns)
 	for i := range result {
 		if r.Float64() < prob {
@@ -85,10 +92,91 @@ func NewRandomUnitMatrix(rows, columns uint, prob float64) Matrix {
 	return Matrix{mat.NewDense(int(rows), int(columns), result)}
 }
 
+// NewRandomNormalMatrixFrom creates a matrix of values drawn from a normal distribution with zero mean and the
+// given standard deviation
+func NewRandomNormalMatrixFrom(r *rand.Rand, rows, columns uint, stdDev float64) Matrix {
+	result := make([]float64, rows*columns)
+	for i := range result {
+		result[i] = r.NormFloat64() * stdDev
+	}
+	return Matrix{mat.NewDense(int(rows), int(columns), result)}
+}
+
+// NewRandomUniformMatrixFrom creates a matrix of values drawn uniformly from [-limit, limit)
+func NewRandomUniformMatrixFrom(r *rand.Rand, rows, columns uint, limit float64) Matrix {
+	result := make([]float64, rows*columns)
+	for i := range result {
+		result[i] = (2*r.Float64() - 1) * limit
+	}
+	return Matrix{mat.NewDense(int(rows), int(columns), result)}
+}
+
+// NewRandomOrthogonalMatrixFrom creates a random matrix with orthonormal rows or columns (whichever are fewer),
+// scaled by gain. It is generated from the QR decomposition of a matrix drawn from a normal distribution.
+func NewRandomOrthogonalMatrixFrom(r *rand.Rand, rows, columns uint, gain float64) Matrix {
+	// The QR decomposition needs at least as many rows as columns, so decompose the transpose otherwise
+	n, m := rows, columns
+	transpose := rows < columns
+	if transpose {
+		n, m = columns, rows
+	}
+	a := NewRandomNormalMatrixFrom(r, n, m, 1)
+	var qr mat.QR
+	qr.Factorize(a.imp)
+	var q, rr mat.Dense
+	qr.QTo(&q)
+	qr.RTo(&rr)
+
+	// Use the first m columns of Q, flipping their signs to match the diagonal of R so the result is uniformly
+	// distributed
+	result := mat.NewDense(int(n), int(m), nil)
+	for j := 0; j < int(m); j++ {
+		sign := 1.0
+		if rr.At(j, j) < 0 {
+			sign = -1
+		}
+		for i := 0; i < int(n); i++ {
+			result.Set(i, j, q.At(i, j)*sign*gain)
+		}
+	}
+	if transpose {
+		return Matrix{mat.DenseCopyOf(result.T())}
+	}
+	return Matrix{result}
+}
+
+// NewConstantMatrix creates a matrix with every value set to value
+func NewConstantMatrix(rows, columns uint, value float64) Matrix {
+	result := make([]float64, rows*columns)
+	for i := range result {
+		result[i] = value
+	}
+	return Matrix{mat.NewDense(int(rows), int(columns), result)}
+}
+
+func newTimeSeededRand() *rand.Rand {
+	return rand.New(rand.NewSource(time.Now().UnixNano()))
+}
+
 func NewZeroMatrix(rows, columns uint) Matrix {
 	return Matrix{mat.NewDense(int(rows), int(columns), nil)}
 }
 
+// NewMatrix creates a matrix from values given in row-major order
+func NewMatrix(rows, columns uint, values []float64) Matrix {
+	if uint(len(values)) != rows*columns {
+		panic("Number of values must match the matrix dimensions")
+	}
+	data := make([]float64, len(values))
+	copy(data, values)
+	return Matrix{mat.NewDense(int(rows), int(columns), data)}
+}
+
+// Clone returns a copy of the matrix that doesn't share its values
+func (m Matrix) Clone() Matrix {
+	return Matrix{mat.DenseCopyOf(m.imp)}
+}
+
 func NewHorizontalStackedMatrix(vectors [][]float64) Matrix {
 	result := mat.NewDense(len(vectors[0]), len(vectors), nil)
 	for j := 0; j < len(vectors); j++ {
@@ -131,6 +219,64 @@ func (m Matrix) MatrixElemOp(a, b MatrixViewable, f func(v1, v2 float64) float64
 	m.imp.Apply(applyFunc, a.View().view)
 }
 
+// Softmax applies the softmax function to each column of a, so every column of the result sums to 1
+func (m Matrix) Softmax(a MatrixViewable) {
+	r, c := a.Dims()
+	for j := 0; j < c; j++ {
+		// Subtract the max value of the column to avoid overflowing the exponentials
+		max := math.Inf(-1)
+		for i := 0; i < r; i++ {
+			max = math.Max(max, a.At(i, j))
+		}
+		sum := 0.0
+		for i := 0; i < r; i++ {
+			v := math.Exp(a.At(i, j) - max)
+			m.imp.Set(i, j, v)
+			sum += v
+		}
+		for i := 0; i < r; i++ {
+			m.imp.Set(i, j, m.imp.At(i, j)/sum)
+		}
+	}
+}
+
+// SoftmaxBackward calculates the gradient with respect to the inputs of the softmax function, given its output
+// a and the gradient da with respect to that output
+func (m Matrix) SoftmaxBackward(a, da MatrixViewable) {
+	r, c := a.Dims()
+	for j := 0; j < c; j++ {
+		dot := 0.0
+		for i := 0; i < r; i++ {
+			dot += a.At(i, j) * da.At(i, j)
+		}
+		for i := 0; i < r; i++ {
+			m.imp.Set(i, j, a.At(i, j)*(da.At(i, j)-dot))
+		}
+	}
+}
+
+// CopyColumns sets each column j of m to the column columns[j] of a
+func (m Matrix) CopyColumns(a MatrixViewable, columns []int) {
+	r, _ := m.Dims()
+	for j, column := range columns {
+		for i := 0; i < r; i++ {
+			m.imp.Set(i, j, a.At(i, column))
+		}
+	}
+}
+
+// ColumnArgMax returns the row index of the largest value in the given column
+func ColumnArgMax(a MatrixViewable, column int) int {
+	r, _ := a.Dims()
+	index := 0
+	for i := 1; i < r; i++ {
+		if a.At(i, column) > a.At(index, column) {
+			index = i
+		}
+	}
+	return index
+}
+
 func (m Matrix) RowSum(matToSum MatrixViewable, normalize bool) {
 	rRes, cRes := m.Dims()
 	r, c := matToSum.Dims()
@@ -180,6 +326,16 @@ func (m Matrix) SliceColumns(start, end int) MatrixView {
 	return MatrixView{view: m.imp.Slice(0, m.imp.RawMatrix().Rows, start, end)}
 }
 
+// Values returns a copy of the values of the matrix in row-major order
+func (m Matrix) Values() []float64 {
+	r, c := m.Dims()
+	values := make([]float64, 0, r*c)
+	for i := 0; i < r; i++ {
+		values = append(values, m.imp.RawRowView(i)...)
+	}
+	return values
+}
+
 func (m Matrix) String() string {
 	return fmt.Sprintf("%+v", *m.imp)
 }
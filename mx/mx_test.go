@@ -1,6 +1,8 @@
 package mx_test
 
 import (
+	"math"
+	"math/rand"
 	"testing"
 
 	"github.com/codehex/neuralnet/mx"
@@ -106,3 +108,181 @@ func TestRowSum(t *testing.T) {
 		t.Errorf("Expected matrix value at (1, 0) to be 15, but got %v", m.At(1, 0))
 	}
 }
+
+func TestSoftmax(t *testing.T) {
+	a := mx.NewHorizontalStackedMatrix([][]float64{
+		{1, 2, 3},
+		{1000, 1000, 1000},
+	})
+	m := mx.NewZeroMatrix(3, 2)
+	m.Softmax(a)
+
+	for j := 0; j < 2; j++ {
+		sum := 0.0
+		for i := 0; i < 3; i++ {
+			sum += m.At(i, j)
+		}
+		if math.Abs(sum-1) > 1e-9 {
+			t.Errorf("Expected column %d to sum to 1, but got %v", j, sum)
+		}
+	}
+	if !(m.At(0, 0) < m.At(1, 0) && m.At(1, 0) < m.At(2, 0)) {
+		t.Errorf("Expected softmax to preserve ordering, but got %v, %v, %v", m.At(0, 0), m.At(1, 0), m.At(2, 0))
+	}
+	if math.Abs(m.At(0, 1)-1.0/3) > 1e-9 {
+		t.Errorf("Expected matrix value at (0, 1) to be 1/3, but got %v", m.At(0, 1))
+	}
+}
+
+func TestSoftmaxBackward(t *testing.T) {
+	z := mx.NewMatrix(3, 1, []float64{1, 2, 0.5})
+	da := mx.NewMatrix(3, 1, []float64{0.3, -1, 2})
+	a := mx.NewZeroMatrix(3, 1)
+	a.Softmax(z)
+	dz := mx.NewZeroMatrix(3, 1)
+	dz.SoftmaxBackward(a, da)
+
+	// Compare with the numerical gradient of sum(da * softmax(z))
+	const h = 1e-6
+	for i := 0; i < 3; i++ {
+		output := func(delta float64) float64 {
+			shifted := z.Clone()
+			shifted.Set(i, 0, z.At(i, 0)+delta)
+			s := mx.NewZeroMatrix(3, 1)
+			s.Softmax(shifted)
+			sum := 0.0
+			for k := 0; k < 3; k++ {
+				sum += da.At(k, 0) * s.At(k, 0)
+			}
+			return sum
+		}
+		expected := (output(h) - output(-h)) / (2 * h)
+		if math.Abs(dz.At(i, 0)-expected) > 1e-6 {
+			t.Errorf("Expected gradient %v for row %d, but got %v", expected, i, dz.At(i, 0))
+		}
+	}
+}
+
+func TestCopyColumns(t *testing.T) {
+	a := mx.NewMatrix(2, 3, []float64{1, 2, 3, 4, 5, 6})
+	m := mx.NewZeroMatrix(2, 2)
+	m.CopyColumns(a, []int{2, 0})
+
+	expected := []float64{3, 1, 6, 4}
+	for i, v := range m.Values() {
+		if v != expected[i] {
+			t.Errorf("Expected %v, but got %v", expected, m.Values())
+			break
+		}
+	}
+}
+
+func TestColumnArgMax(t *testing.T) {
+	m := mx.NewHorizontalStackedMatrix([][]float64{
+		{0.1, 0.7, 0.2},
+		{0.5, 0.2, 0.3},
+	})
+	if i := mx.ColumnArgMax(m, 0); i != 1 {
+		t.Errorf("Expected argmax of column 0 to be 1, but got %d", i)
+	}
+	if i := mx.ColumnArgMax(m, 1); i != 0 {
+		t.Errorf("Expected argmax of column 1 to be 0, but got %d", i)
+	}
+}
+
+func TestNewMatrixValues(t *testing.T) {
+	values := []float64{1, 2, 3, 4, 5, 6}
+	m := mx.NewMatrix(2, 3, values)
+	if m.At(0, 2) != 3 {
+		t.Errorf("Expected matrix value at (0, 2) to be 3, but got %v", m.At(0, 2))
+	}
+	if m.At(1, 0) != 4 {
+		t.Errorf("Expected matrix value at (1, 0) to be 4, but got %v", m.At(1, 0))
+	}
+
+	values[0] = 100
+	result := m.Values()
+	if len(result) != 6 {
+		t.Fatalf("Expected 6 values, but got %d", len(result))
+	}
+	for i, v := range []float64{1, 2, 3, 4, 5, 6} {
+		if result[i] != v {
+			t.Errorf("Expected value %d to be %v, but got %v", i, v, result[i])
+		}
+	}
+}
+
+func TestNewRandomMatrixFromSeed(t *testing.T) {
+	m1 := mx.NewRandomMatrixFrom(rand.New(rand.NewSource(42)), 3, 4, 1)
+	m2 := mx.NewRandomMatrixFrom(rand.New(rand.NewSource(42)), 3, 4, 1)
+	for i := 0; i < 3; i++ {
+		for j := 0; j < 4; j++ {
+			if m1.At(i, j) != m2.At(i, j) {
+				t.Errorf("Expected matrices with the same seed to be equal at (%v, %v), but got %v and %v", i, j, m1.At(i, j), m2.At(i, j))
+			}
+		}
+	}
+}
+
+func TestNewRandomNormalMatrixFrom(t *testing.T) {
+	m := mx.NewRandomNormalMatrixFrom(rand.New(rand.NewSource(1)), 100, 100, 2)
+	values := m.Values()
+	mean, variance := 0.0, 0.0
+	for _, v := range values {
+		mean += v
+	}
+	mean /= float64(len(values))
+	for _, v := range values {
+		variance += (v - mean) * (v - mean)
+	}
+	variance /= float64(len(values))
+	if math.Abs(mean) > 0.1 {
+		t.Errorf("Expected mean to be close to 0, but got %v", mean)
+	}
+	if math.Abs(math.Sqrt(variance)-2) > 0.1 {
+		t.Errorf("Expected standard deviation to be close to 2, but got %v", math.Sqrt(variance))
+	}
+}
+
+func TestNewRandomUniformMatrixFrom(t *testing.T) {
+	m := mx.NewRandomUniformMatrixFrom(rand.New(rand.NewSource(1)), 10, 10, 0.5)
+	negative := false
+	for _, v := range m.Values() {
+		if v < -0.5 || v >= 0.5 {
+			t.Errorf("Expected values to be within [-0.5, 0.5), but got %v", v)
+		}
+		negative = negative || v < 0
+	}
+	if !negative {
+		t.Errorf("Expected some values to be negative")
+	}
+}
+
+func TestNewRandomOrthogonalMatrixFrom(t *testing.T) {
+	for _, dims := range [][2]uint{{5, 3}, {3, 5}} {
+		m := mx.NewRandomOrthogonalMatrixFrom(rand.New(rand.NewSource(1)), dims[0], dims[1], 1)
+		r, c := m.Dims()
+		if r != int(dims[0]) || c != int(dims[1]) {
+			t.Fatalf("Expected matrix dimensions to be %v, but got (%d, %d)", dims, r, c)
+		}
+
+		// The smaller dimension should be orthonormal
+		product := mx.NewZeroMatrix(3, 3)
+		if r > c {
+			product.MatrixMultiply(m.Transpose(), m)
+		} else {
+			product.MatrixMultiply(m, m.Transpose())
+		}
+		for i := 0; i < 3; i++ {
+			for j := 0; j < 3; j++ {
+				expected := 0.0
+				if i == j {
+					expected = 1
+				}
+				if math.Abs(product.At(i, j)-expected) > 1e-9 {
+					t.Errorf("Expected product at (%d, %d) to be %v, but got %v", i, j, expected, product.At(i, j))
+				}
+			}
+		}
+	}
+}
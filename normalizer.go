@@ -0,0 +1,69 @@
+package neuralnet
+
+import (
+	"fmt"
+	"math"
+)
+
+// imageChannels is the number of features generated per pixel (red, green and blue)
+const imageChannels = 3
+
+// Normalizer standardizes feature vectors to zero mean and unit standard deviation. It is fit on the training set
+// and the same statistics must be reused for any later set, so the model sees inputs on the same scale.
+type Normalizer struct {
+	Mean   []float64
+	StdDev []float64
+}
+
+// fitNormalizer calculates the mean and standard deviation of each feature. If perChannel is set, the statistics
+// are shared across all pixels of the same colour channel rather than calculated per feature.
+func fitNormalizer(vectors [][]float64, perChannel bool) *Normalizer {
+	featureCount := len(vectors[0])
+	groups := featureCount
+	group := func(featureIndex int) int { return featureIndex }
+	if perChannel {
+		groups = imageChannels
+		group = func(featureIndex int) int { return featureIndex % imageChannels }
+	}
+
+	// Calulate sum and sum of squares
+	sum := make([]float64, groups)
+	sumSquared := make([]float64, groups)
+	count := make([]float64, groups)
+	for _, vector := range vectors {
+		for featureIndex, v := range vector {
+			g := group(featureIndex)
+			sum[g] += v
+			sumSquared[g] += v * v
+			count[g]++
+		}
+	}
+
+	n := &Normalizer{Mean: make([]float64, featureCount), StdDev: make([]float64, featureCount)}
+	for featureIndex := range n.Mean {
+		g := group(featureIndex)
+		mean := sum[g] / count[g]
+		variance := math.Max(sumSquared[g]/count[g]-mean*mean, 0)
+		n.Mean[featureIndex] = mean
+		n.StdDev[featureIndex] = math.Sqrt(variance)
+	}
+	return n
+}
+
+func (n *Normalizer) validate(featureCount uint) error {
+	if uint(len(n.Mean)) != featureCount || uint(len(n.StdDev)) != featureCount {
+		return fmt.Errorf("normalizer has statistics for %d features, but the set has %d features", len(n.Mean), featureCount)
+	}
+	return nil
+}
+
+// apply normalizes the feature vector in place
+func (n *Normalizer) apply(vector []float64) {
+	for i := range vector {
+		vector[i] -= n.Mean[i]
+		// Constant features (e.g. a border that is always black) are only centred
+		if n.StdDev[i] > 0 {
+			vector[i] /= n.StdDev[i]
+		}
+	}
+}
@@ -0,0 +1,243 @@
+package neuralnet
+
+import (
+	"errors"
+	"fmt"
+	"math"
+
+	"github.com/codehex/neuralnet/mx"
+)
+
+type OptimizerName string
+
+const (
+	OptimizerNameGradientDescent OptimizerName = "gradient-descent"
+	OptimizerNameMomentum        OptimizerName = "momentum"
+	OptimizerNameNesterov        OptimizerName = "nesterov"
+	OptimizerNameRMSProp         OptimizerName = "rmsprop"
+	OptimizerNameAdaGrad         OptimizerName = "adagrad"
+	OptimizerNameAdam            OptimizerName = "adam"
+	OptimizerNameAdamW           OptimizerName = "adamw"
+)
+
+// optimizerConfig holds the settings of the optimizer chosen on the HyperParametersBuilder. A new optimizer, with
+// its own state, is created from it for every model trained.
+type optimizerConfig struct {
+	name        OptimizerName
+	beta1       float64
+	beta2       float64
+	epsilon     float64
+	weightDecay float64
+}
+
+func (c optimizerConfig) validate() error {
+	switch c.name {
+	case OptimizerNameGradientDescent:
+		return nil
+	case OptimizerNameMomentum, OptimizerNameNesterov, OptimizerNameRMSProp:
+		if c.beta1 <= 0 || c.beta1 >= 1 {
+			return fmt.Errorf("%s beta must be between 0 and 1", c.name)
+		}
+	case OptimizerNameAdam, OptimizerNameAdamW:
+		if c.beta1 < 0 || c.beta1 >= 1 || c.beta2 < 0 || c.beta2 >= 1 {
+			return fmt.Errorf("%s betas must be between 0 and 1", c.name)
+		}
+		if c.weightDecay < 0 {
+			return errors.New("weight decay cannot be negative")
+		}
+	case OptimizerNameAdaGrad:
+	default:
+		return fmt.Errorf("unknown optimizer '%s'", c.name)
+	}
+	if c.name != OptimizerNameMomentum && c.name != OptimizerNameNesterov && c.epsilon <= 0 {
+		return fmt.Errorf("%s epsilon must be greater than 0", c.name)
+	}
+	return nil
+}
+
+func (c optimizerConfig) String() string {
+	switch c.name {
+	case OptimizerNameMomentum, OptimizerNameNesterov:
+		return fmt.Sprintf("%s (beta %.5g)", c.name, c.beta1)
+	case OptimizerNameRMSProp:
+		return fmt.Sprintf("%s (beta %.5g, epsilon %.5g)", c.name, c.beta1, c.epsilon)
+	case OptimizerNameAdaGrad:
+		return fmt.Sprintf("%s (epsilon %.5g)", c.name, c.epsilon)
+	case OptimizerNameAdam:
+		return fmt.Sprintf("%s (beta1 %.5g, beta2 %.5g, epsilon %.5g)", c.name, c.beta1, c.beta2, c.epsilon)
+	case OptimizerNameAdamW:
+		return fmt.Sprintf("%s (beta1 %.5g, beta2 %.5g, epsilon %.5g, weight decay %.5g)",
+			c.name, c.beta1, c.beta2, c.epsilon, c.weightDecay)
+	default:
+		return string(c.name)
+	}
+}
+
+// optimizer updates the parameters of a layer using the gradients calculated by backward propagation
+type optimizer interface {
+	// update applies the gradients of layer i to its weights and biases, where step is the number of the
+	// update being made (starting at 1)
+	update(params *parameters, cache []cacheLayer, i int, learningRate float64, step uint)
+	// state returns the per layer buffers the optimizer keeps between updates
+	state() []*parameters
+}
+
+// layerUpdate pairs a parameter matrix with its gradient and the matching optimizer buffers
+type layerUpdate struct {
+	x, dx   mx.Matrix
+	buffers []mx.Matrix
+}
+
+func newOptimizer(config optimizerConfig, nodes []uint) optimizer {
+	switch config.name {
+	case OptimizerNameMomentum:
+		return &momentumOptimizer{beta: config.beta1, v: newLayerBuffers(nodes)}
+	case OptimizerNameNesterov:
+		return &momentumOptimizer{beta: config.beta1, nesterov: true, v: newLayerBuffers(nodes)}
+	case OptimizerNameRMSProp:
+		return &rmsPropOptimizer{beta: config.beta1, epsilon: config.epsilon, s: newLayerBuffers(nodes), delta: newLayerBuffers(nodes)}
+	case OptimizerNameAdaGrad:
+		return &rmsPropOptimizer{adaGrad: true, epsilon: config.epsilon, s: newLayerBuffers(nodes), delta: newLayerBuffers(nodes)}
+	case OptimizerNameAdam, OptimizerNameAdamW:
+		return &adamOptimizer{
+			beta1: config.beta1, beta2: config.beta2, epsilon: config.epsilon, weightDecay: config.weightDecay,
+			m: newLayerBuffers(nodes), v: newLayerBuffers(nodes), delta: newLayerBuffers(nodes),
+		}
+	default:
+		return gradientDescentOptimizer{}
+	}
+}
+
+// newLayerBuffers creates zero matrices with the same dimensions as the weights, biases and gamma of each layer
+func newLayerBuffers(nodes []uint) *parameters {
+	buffers := newParameters(len(nodes))
+	for i := 1; i < len(nodes); i++ {
+		buffers.W[i] = mx.NewZeroMatrix(nodes[i], nodes[i-1])
+		buffers.b[i] = mx.NewZeroMatrix(nodes[i], 1)
+		// Every layer gets buffers for gamma, but they're only used by batch normalized layers
+		buffers.gamma[i] = mx.NewZeroMatrix(nodes[i], 1)
+	}
+	return &buffers
+}
+
+// layerUpdates returns the weights, biases and (for batch normalized layers) gamma of layer i, with their gradients
+// and the given buffers. The weights always come first.
+func layerUpdates(params *parameters, cache []cacheLayer, i int, buffers ...*parameters) []layerUpdate {
+	weights := layerUpdate{x: params.W[i], dx: cache[i].DW}
+	biases := layerUpdate{x: params.b[i], dx: cache[i].Db}
+	for _, buffer := range buffers {
+		weights.buffers = append(weights.buffers, buffer.W[i])
+		biases.buffers = append(biases.buffers, buffer.b[i])
+	}
+	if !params.batchNormalized(i) {
+		return []layerUpdate{weights, biases}
+	}
+	gamma := layerUpdate{x: params.gamma[i], dx: cache[i].DGamma}
+	for _, buffer := range buffers {
+		gamma.buffers = append(gamma.buffers, buffer.gamma[i])
+	}
+	return []layerUpdate{weights, biases, gamma}
+}
+
+func descend(learningRate float64) func(x, dx float64) float64 {
+	return func(x, dx float64) float64 { return x - learningRate*dx }
+}
+
+// gradientDescentOptimizer moves the parameters directly against the gradient
+type gradientDescentOptimizer struct{}
+
+func (o gradientDescentOptimizer) update(params *parameters, cache []cacheLayer, i int, learningRate float64, step uint) {
+	for _, u := range layerUpdates(params, cache, i) {
+		u.x.MatrixElemOp(u.x, u.dx, descend(learningRate))
+	}
+}
+
+func (o gradientDescentOptimizer) state() []*parameters {
+	return nil
+}
+
+// momentumOptimizer uses an exponential moving average of the gradients, dampening out oscillations. With nesterov
+// set, the parameters are moved using the gradient looked ahead along the updated velocity.
+type momentumOptimizer struct {
+	beta     float64
+	nesterov bool
+	v        *parameters
+}
+
+func (o *momentumOptimizer) update(params *parameters, cache []cacheLayer, i int, learningRate float64, step uint) {
+	updateMomentFunc := func(v1, v2 float64) float64 {
+		return (o.beta * v1) + ((1 - o.beta) * v2)
+	}
+	for _, u := range layerUpdates(params, cache, i, o.v) {
+		v := u.buffers[0]
+		v.MatrixElemOp(v, u.dx, updateMomentFunc)
+		if !o.nesterov {
+			u.x.MatrixElemOp(u.x, v, descend(learningRate))
+			continue
+		}
+		// Nesterov looks ahead by combining the updated velocity with the current gradient
+		u.x.MatrixElemOp(u.x, v, func(x, v float64) float64 { return x - learningRate*o.beta*v })
+		u.x.MatrixElemOp(u.x, u.dx, func(x, dx float64) float64 { return x - learningRate*(1-o.beta)*dx })
+	}
+}
+
+func (o *momentumOptimizer) state() []*parameters {
+	return []*parameters{o.v}
+}
+
+// rmsPropOptimizer scales the learning rate of each parameter by the root of its squared gradients, averaged
+// exponentially for RMSProp or accumulated over all updates for AdaGrad
+type rmsPropOptimizer struct {
+	beta     float64
+	epsilon  float64
+	adaGrad  bool
+	s, delta *parameters
+}
+
+func (o *rmsPropOptimizer) update(params *parameters, cache []cacheLayer, i int, learningRate float64, step uint) {
+	accumulate := func(s, dx float64) float64 { return (o.beta * s) + ((1 - o.beta) * dx * dx) }
+	if o.adaGrad {
+		accumulate = func(s, dx float64) float64 { return s + (dx * dx) }
+	}
+	for _, u := range layerUpdates(params, cache, i, o.s, o.delta) {
+		s, delta := u.buffers[0], u.buffers[1]
+		s.MatrixElemOp(s, u.dx, accumulate)
+		delta.MatrixElemOp(u.dx, s, func(dx, s float64) float64 { return dx / (math.Sqrt(s) + o.epsilon) })
+		u.x.MatrixElemOp(u.x, delta, descend(learningRate))
+	}
+}
+
+func (o *rmsPropOptimizer) state() []*parameters {
+	return []*parameters{o.s}
+}
+
+// adamOptimizer combines momentum with RMSProp, correcting the bias of both averages towards zero in the first
+// updates. A non-zero weightDecay gives AdamW, decaying the weights directly rather than through the gradients.
+type adamOptimizer struct {
+	beta1, beta2 float64
+	epsilon      float64
+	weightDecay  float64
+	m, v, delta  *parameters
+}
+
+func (o *adamOptimizer) update(params *parameters, cache []cacheLayer, i int, learningRate float64, step uint) {
+	correction1 := 1 - math.Pow(o.beta1, float64(step))
+	correction2 := 1 - math.Pow(o.beta2, float64(step))
+	for index, u := range layerUpdates(params, cache, i, o.m, o.v, o.delta) {
+		m, v, delta := u.buffers[0], u.buffers[1], u.buffers[2]
+		m.MatrixElemOp(m, u.dx, func(m, dx float64) float64 { return (o.beta1 * m) + ((1 - o.beta1) * dx) })
+		v.MatrixElemOp(v, u.dx, func(v, dx float64) float64 { return (o.beta2 * v) + ((1 - o.beta2) * dx * dx) })
+		delta.MatrixElemOp(m, v, func(m, v float64) float64 {
+			return (m / correction1) / (math.Sqrt(v/correction2) + o.epsilon)
+		})
+		// Only the weights are decayed, not the biases or gamma
+		if o.weightDecay != 0 && index == 0 {
+			delta.MatrixElemOp(delta, u.x, func(d, x float64) float64 { return d + (o.weightDecay * x) })
+		}
+		u.x.MatrixElemOp(u.x, delta, descend(learningRate))
+	}
+}
+
+func (o *adamOptimizer) state() []*parameters {
+	return []*parameters{o.m, o.v}
+}
@@ -0,0 +1,373 @@
+package neuralnet
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+
+	"github.com/codehex/neuralnet/mx"
+)
+
+// modelFormat identifies a file as a saved neuralnet model
+const modelFormat = "neuralnet-model"
+
+// modelFormatVersion is the current version of the saved model format. It must be incremented whenever the
+// format changes in a way older versions of LoadModel cannot read.
+const modelFormatVersion = 2
+
+// Preprocessing describes how images were converted into feature vectors when training a model. Images used for
+// predictions must go through the same steps, see ImageSetBuilder.WithPreprocessing.
+type Preprocessing struct {
+	Width, Height uint
+	// Normalizer holds the statistics fitted on the training set, or nil if it wasn't normalized
+	Normalizer *Normalizer
+}
+
+// modelFile is the JSON document written by TrainedModel.Save. Version 2 of the format is
+//
+//	{
+//	  "format": "neuralnet-model",
+//	  "version": 2,
+//	  "classes": ["cabbage", "carrot"],
+//	  "preprocessing": {"width": 64, "height": 64, "normalizer": {"mean": [...], "stdDev": [...]}},
+//	  "hyperParameters": {"learningRate": 0.5, "iterations": 2000, ...},
+//	  "layers": [
+//	    {"neurons": 6, "inputs": 12288, "activation": "relu", "weights": [...], "biases": [...]},
+//	    ...
+//	  ]
+//	}
+//
+// where "classes" is replaced by "regression": true for models predicting continuous values, "multiLabel": true is
+// added for models predicting any number of classes per example, batch normalized layers also hold "gamma",
+// "runningMean" and "runningVariance" (added in version 2), and the weights of each layer are stored in row-major
+// order, with a row per neuron and a column per input. Version 1 stored "normalize": true in place of the
+// statistics of the normalizer, so normalized version 1 models cannot be loaded, and "momentumBeta" in place of
+// the optimizer, which is read as gradient descent with momentum if it isn't 0.
+type modelFile struct {
+	Format          string              `json:"format"`
+	Version         int                 `json:"version"`
+	Classes         []string            `json:"classes"`
+	Regression      bool                `json:"regression,omitempty"`
+	MultiLabel      bool                `json:"multiLabel,omitempty"`
+	Preprocessing   preprocessingFile   `json:"preprocessing"`
+	HyperParameters hyperParametersFile `json:"hyperParameters"`
+	Layers          []layerFile         `json:"layers"`
+}
+
+type preprocessingFile struct {
+	Width      uint            `json:"width"`
+	Height     uint            `json:"height"`
+	Normalizer *normalizerFile `json:"normalizer,omitempty"`
+	// Normalize is only read from version 1 files
+	Normalize bool `json:"normalize,omitempty"`
+}
+
+type normalizerFile struct {
+	Mean   []float64 `json:"mean"`
+	StdDev []float64 `json:"stdDev"`
+}
+
+type hyperParametersFile struct {
+	LearningRate         float64        `json:"learningRate"`
+	Iterations           uint           `json:"iterations"`
+	RegularizationFactor float64        `json:"regularizationFactor,omitempty"`
+	KeepProb             float64        `json:"dropoutKeepProbability,omitempty"`
+	MiniBatchSize        uint           `json:"miniBatchSize,omitempty"`
+	Loss                 lossFile       `json:"loss"`
+	Optimizer            optimizerFile  `json:"optimizer"`
+	BatchNorm            *batchNormFile `json:"batchNormalization,omitempty"`
+	// MomentumBeta is only read from version 1 files
+	MomentumBeta float64 `json:"momentumBeta,omitempty"`
+}
+
+type batchNormFile struct {
+	Momentum float64 `json:"momentum" yaml:"momentum"`
+	Epsilon  float64 `json:"epsilon" yaml:"epsilon"`
+}
+
+type lossFile struct {
+	Name           LossName `json:"name" yaml:"name"`
+	PositiveWeight float64  `json:"positiveWeight,omitempty" yaml:"positiveWeight,omitempty"`
+	Gamma          float64  `json:"gamma,omitempty" yaml:"gamma,omitempty"`
+	Alpha          float64  `json:"alpha,omitempty" yaml:"alpha,omitempty"`
+	Delta          float64  `json:"delta,omitempty" yaml:"delta,omitempty"`
+}
+
+type optimizerFile struct {
+	Name        OptimizerName `json:"name" yaml:"name"`
+	Beta1       float64       `json:"beta1,omitempty" yaml:"beta1,omitempty"`
+	Beta2       float64       `json:"beta2,omitempty" yaml:"beta2,omitempty"`
+	Epsilon     float64       `json:"epsilon,omitempty" yaml:"epsilon,omitempty"`
+	WeightDecay float64       `json:"weightDecay,omitempty" yaml:"weightDecay,omitempty"`
+}
+
+type layerFile struct {
+	Neurons    uint               `json:"neurons"`
+	Inputs     uint               `json:"inputs"`
+	Activation ActivationFuncName `json:"activation"`
+	Weights    []float64          `json:"weights"`
+	Biases     []float64          `json:"biases"`
+	// Gamma and the running statistics are only stored for batch normalized layers
+	Gamma           []float64 `json:"gamma,omitempty"`
+	RunningMean     []float64 `json:"runningMean,omitempty"`
+	RunningVariance []float64 `json:"runningVariance,omitempty"`
+}
+
+// layerValuesFile holds the values of a matrix per layer, such as optimizer buffers, in row-major order
+type layerValuesFile struct {
+	Weights []float64 `json:"weights"`
+	Biases  []float64 `json:"biases"`
+	// Gamma, Mean and Variance are only stored for layers that have them
+	Gamma    []float64 `json:"gamma,omitempty"`
+	Mean     []float64 `json:"mean,omitempty"`
+	Variance []float64 `json:"variance,omitempty"`
+}
+
+// Save writes the trained model to w as a versioned JSON document, so it can be restored with LoadModel
+func (t *TrainedModel) Save(w io.Writer) error {
+	encoder := json.NewEncoder(w)
+	if err := encoder.Encode(t.toFile()); err != nil {
+		return fmt.Errorf("error writing model: %w", err)
+	}
+	return nil
+}
+
+// LoadModel reads a model previously written by TrainedModel.Save
+func LoadModel(r io.Reader) (*TrainedModel, error) {
+	var file modelFile
+	if err := json.NewDecoder(r).Decode(&file); err != nil {
+		return nil, fmt.Errorf("error reading model: %w", err)
+	}
+	return modelFromFile(file)
+}
+
+func (t *TrainedModel) toFile() modelFile {
+	file := modelFile{
+		Format:     modelFormat,
+		Version:    modelFormatVersion,
+		Classes:    t.classes,
+		Regression: t.Regression(),
+		MultiLabel: t.MultiLabel(),
+		Preprocessing: preprocessingFile{
+			Width:  t.preprocessing.Width,
+			Height: t.preprocessing.Height,
+		},
+		HyperParameters: hyperParametersFile{
+			LearningRate:         t.hyper.learningRate,
+			Iterations:           t.hyper.iterations,
+			RegularizationFactor: t.hyper.regularizationFactor,
+			KeepProb:             t.hyper.keepProb,
+			MiniBatchSize:        t.hyper.miniBatchSize,
+			Loss:                 lossFile(t.hyper.loss),
+			Optimizer: optimizerFile{
+				Name:        t.hyper.optimizer.name,
+				Beta1:       t.hyper.optimizer.beta1,
+				Beta2:       t.hyper.optimizer.beta2,
+				Epsilon:     t.hyper.optimizer.epsilon,
+				WeightDecay: t.hyper.optimizer.weightDecay,
+			},
+		},
+	}
+	if t.hyper.batchNormalized() {
+		file.HyperParameters.BatchNorm = &batchNormFile{Momentum: t.hyper.batchNorm.momentum, Epsilon: t.hyper.batchNorm.epsilon}
+	}
+	if n := t.preprocessing.Normalizer; n != nil {
+		file.Preprocessing.Normalizer = &normalizerFile{Mean: n.Mean, StdDev: n.StdDev}
+	}
+	for i := 1; i < len(t.params.W); i++ {
+		_, inputs := t.params.W[i].Dims()
+		layer := layerFile{
+			Neurons:    t.hyper.Layer(i).neurons,
+			Inputs:     uint(inputs),
+			Activation: t.hyper.Layer(i).actFuncLabel,
+			Weights:    t.params.W[i].Values(),
+			Biases:     t.params.b[i].Values(),
+		}
+		if t.params.batchNormalized(i) {
+			layer.Gamma = t.params.gamma[i].Values()
+			layer.RunningMean = t.params.mean[i].Values()
+			layer.RunningVariance = t.params.variance[i].Values()
+		}
+		file.Layers = append(file.Layers, layer)
+	}
+	return file
+}
+
+func modelFromFile(file modelFile) (*TrainedModel, error) {
+	if file.Format != modelFormat {
+		return nil, fmt.Errorf("unknown model format '%s'", file.Format)
+	}
+	if file.Version < 1 || file.Version > modelFormatVersion {
+		return nil, fmt.Errorf("unsupported model format version %d, expected at most %d", file.Version, modelFormatVersion)
+	}
+	if len(file.Layers) == 0 {
+		return nil, errors.New("model has no layers")
+	}
+	if file.Preprocessing.Normalize && file.Preprocessing.Normalizer == nil {
+		return nil, fmt.Errorf("model format version %d normalized every set with its own statistics, which aren't "+
+			"stored in the file, train and save the model again", file.Version)
+	}
+
+	builder := NewHyperParametersBuilder().
+		SetLearningRate(file.HyperParameters.LearningRate).
+		SetIterations(file.HyperParameters.Iterations).
+		SetRegularizationFactor(file.HyperParameters.RegularizationFactor).
+		SetDropoutKeepProbability(file.HyperParameters.KeepProb).
+		SetMiniBatchSize(file.HyperParameters.MiniBatchSize).
+		SetLoss(Loss(file.HyperParameters.Loss))
+	builder.params.optimizer = optimizerConfig{
+		name:        file.HyperParameters.Optimizer.Name,
+		beta1:       file.HyperParameters.Optimizer.Beta1,
+		beta2:       file.HyperParameters.Optimizer.Beta2,
+		epsilon:     file.HyperParameters.Optimizer.Epsilon,
+		weightDecay: file.HyperParameters.Optimizer.WeightDecay,
+	}
+	if file.Version == 1 && file.HyperParameters.Optimizer.Name == "" {
+		builder.params.optimizer = optimizerConfig{name: OptimizerNameGradientDescent}
+		if beta := file.HyperParameters.MomentumBeta; beta != 0 {
+			builder.params.optimizer = optimizerConfig{name: OptimizerNameMomentum, beta1: beta}
+		}
+	}
+	if b := file.HyperParameters.BatchNorm; b != nil {
+		builder = builder.SetBatchNormalization(b.Momentum, b.Epsilon)
+	}
+
+	// Check every layer before building any matrix, as matrices can't have zero rows or columns
+	for i, layer := range file.Layers {
+		if err := layer.validate(i, file.Layers); err != nil {
+			return nil, err
+		}
+	}
+	params := newParameters(len(file.Layers) + 1)
+	for i, layer := range file.Layers {
+		params.W[i+1] = mx.NewMatrix(layer.Neurons, layer.Inputs, layer.Weights)
+		params.b[i+1] = mx.NewMatrix(layer.Neurons, 1, layer.Biases)
+		if layer.Gamma == nil {
+			builder = builder.AddLayers(layer.Activation, layer.Neurons)
+			continue
+		}
+		builder = builder.AddBatchNormLayers(layer.Activation, layer.Neurons)
+		params.gamma[i+1] = mx.NewMatrix(layer.Neurons, 1, layer.Gamma)
+		params.mean[i+1] = mx.NewMatrix(layer.Neurons, 1, layer.RunningMean)
+		params.variance[i+1] = mx.NewMatrix(layer.Neurons, 1, layer.RunningVariance)
+	}
+
+	hyper, err := builder.Build()
+	if err != nil {
+		return nil, fmt.Errorf("invalid model: %w", err)
+	}
+
+	preprocessing := Preprocessing{Width: file.Preprocessing.Width, Height: file.Preprocessing.Height}
+	if n := file.Preprocessing.Normalizer; n != nil {
+		preprocessing.Normalizer = &Normalizer{Mean: n.Mean, StdDev: n.StdDev}
+		if err := preprocessing.Normalizer.validate(file.Layers[0].Inputs); err != nil {
+			return nil, fmt.Errorf("invalid model: %w", err)
+		}
+	}
+
+	return &TrainedModel{
+		hyper:         hyper,
+		params:        &params,
+		classes:       file.Classes,
+		kind:          kindFromFile(file),
+		preprocessing: preprocessing,
+	}, nil
+}
+
+// validate checks that layer i of the file (counted from 0) has values matching its size and the previous layer
+func (layer layerFile) validate(i int, layers []layerFile) error {
+	if layer.Neurons == 0 || layer.Inputs == 0 {
+		return fmt.Errorf("layer %d has %d neurons and %d inputs, expected at least 1 of each", i+1, layer.Neurons, layer.Inputs)
+	}
+	if i > 0 && layer.Inputs != layers[i-1].Neurons {
+		return fmt.Errorf("layer %d has %d inputs, but the previous layer has %d neurons",
+			i+1, layer.Inputs, layers[i-1].Neurons)
+	}
+	if uint(len(layer.Weights)) != layer.Neurons*layer.Inputs {
+		return fmt.Errorf("layer %d has %d weights, expected %d", i+1, len(layer.Weights), layer.Neurons*layer.Inputs)
+	}
+	if uint(len(layer.Biases)) != layer.Neurons {
+		return fmt.Errorf("layer %d has %d biases, expected %d", i+1, len(layer.Biases), layer.Neurons)
+	}
+	if layer.Gamma != nil && (uint(len(layer.Gamma)) != layer.Neurons || uint(len(layer.RunningMean)) != layer.Neurons ||
+		uint(len(layer.RunningVariance)) != layer.Neurons) {
+		return fmt.Errorf("layer %d has %d gamma values, %d running means and %d running variances, expected %d of each",
+			i+1, len(layer.Gamma), len(layer.RunningMean), len(layer.RunningVariance), layer.Neurons)
+	}
+	return nil
+}
+
+// kindFromFile returns the kind of labels the saved model predicts
+func kindFromFile(file modelFile) labelKind {
+	switch {
+	case file.Regression:
+		return targetValues
+	case file.MultiLabel:
+		return multipleLabels
+	default:
+		return classLabels
+	}
+}
+
+// encodeParameters converts matrices shaped like the weights and biases of each layer into their file format
+func encodeParameters(p *parameters) []layerValuesFile {
+	values := make([]layerValuesFile, 0, len(p.W)-1)
+	for i := 1; i < len(p.W); i++ {
+		layer := layerValuesFile{Weights: p.W[i].Values(), Biases: p.b[i].Values()}
+		if p.batchNormalized(i) {
+			layer.Gamma = p.gamma[i].Values()
+		}
+		if p.batchNormalized(i) && p.mean[i] != (mx.Matrix{}) {
+			layer.Mean = p.mean[i].Values()
+			layer.Variance = p.variance[i].Values()
+		}
+		values = append(values, layer)
+	}
+	return values
+}
+
+// decodeParameters converts the file format back into matrices, checking they match the nodes of each layer
+func decodeParameters(values []layerValuesFile, nodes []uint) (*parameters, error) {
+	if len(values) != len(nodes)-1 {
+		return nil, fmt.Errorf("found values for %d layers, expected %d", len(values), len(nodes)-1)
+	}
+	for i := 1; i < len(nodes); i++ {
+		if nodes[i] == 0 || nodes[i-1] == 0 {
+			return nil, fmt.Errorf("layer %d has %d neurons and %d inputs, expected at least 1 of each", i, nodes[i], nodes[i-1])
+		}
+	}
+	p := newParameters(len(nodes))
+	for i := 1; i < len(nodes); i++ {
+		layer := values[i-1]
+		if uint(len(layer.Weights)) != nodes[i]*nodes[i-1] || uint(len(layer.Biases)) != nodes[i] {
+			return nil, fmt.Errorf("layer %d has %d weights and %d biases, expected %d and %d",
+				i, len(layer.Weights), len(layer.Biases), nodes[i]*nodes[i-1], nodes[i])
+		}
+		p.W[i] = mx.NewMatrix(nodes[i], nodes[i-1], layer.Weights)
+		p.b[i] = mx.NewMatrix(nodes[i], 1, layer.Biases)
+		var err error
+		if p.gamma[i], err = decodeLayerVector(layer.Gamma, i, nodes[i], "gamma"); err != nil {
+			return nil, err
+		}
+		if p.mean[i], err = decodeLayerVector(layer.Mean, i, nodes[i], "mean"); err != nil {
+			return nil, err
+		}
+		if p.variance[i], err = decodeLayerVector(layer.Variance, i, nodes[i], "variance"); err != nil {
+			return nil, err
+		}
+	}
+	return &p, nil
+}
+
+// decodeLayerVector converts the values of a column vector of layer i that is only stored for some layers, returning
+// an empty matrix if there are no values
+func decodeLayerVector(values []float64, i int, rows uint, name string) (mx.Matrix, error) {
+	if values == nil {
+		return mx.Matrix{}, nil
+	}
+	if uint(len(values)) != rows {
+		return mx.Matrix{}, fmt.Errorf("layer %d has %d %s values, expected %d", i, len(values), name, rows)
+	}
+	return mx.NewMatrix(rows, 1, values), nil
+}
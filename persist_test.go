@@ -0,0 +1,183 @@
+package neuralnet_test
+
+import (
+	"bytes"
+	"fmt"
+	"image"
+	"image/color"
+	"image/jpeg"
+	"os"
+	"path"
+	"strings"
+	"testing"
+
+	"github.com/codehex/neuralnet"
+)
+
+// writeTestImages writes count small images of the given colour into dir/folder, with a little noise so the
+// images are not identical
+func writeTestImages(t *testing.T, dir, folder string, count int, c color.RGBA) {
+	t.Helper()
+	if err := os.MkdirAll(path.Join(dir, folder), 0o755); err != nil {
+		t.Fatal(err)
+	}
+	for n := 0; n < count; n++ {
+		img := image.NewRGBA(image.Rect(0, 0, 4, 4))
+		for y := 0; y < 4; y++ {
+			for x := 0; x < 4; x++ {
+				shade := uint8((n*7 + x*3 + y*5) % 32)
+				img.Set(x, y, color.RGBA{c.R + shade, c.G + shade, c.B + shade, 255})
+			}
+		}
+		file, err := os.Create(path.Join(dir, folder, fmt.Sprintf("%d.jpg", n)))
+		if err != nil {
+			t.Fatal(err)
+		}
+		if err := jpeg.Encode(file, img, nil); err != nil {
+			t.Fatal(err)
+		}
+		file.Close()
+	}
+}
+
+// testImageSet builds a normalized set of red and blue 4x4 images
+func testImageSet(t *testing.T) *neuralnet.ImageSet {
+	t.Helper()
+	dir := t.TempDir()
+	writeTestImages(t, dir, "red", 10, color.RGBA{200, 20, 20, 255})
+	writeTestImages(t, dir, "blue", 10, color.RGBA{20, 20, 200, 255})
+
+	set, err := neuralnet.NewImageSetBuilder().
+		WithPathPrefix(dir).
+		AddFolder("red", "red").
+		AddFolder("blue", "blue").
+		Normalize().
+		Build()
+	if err != nil {
+		t.Fatal(err)
+	}
+	return set
+}
+
+// testThreeClassImageSet builds a normalized set of red, green and blue 4x4 images
+func testThreeClassImageSet(t *testing.T) *neuralnet.ImageSet {
+	t.Helper()
+	dir := t.TempDir()
+	writeTestImages(t, dir, "red", 10, color.RGBA{200, 20, 20, 255})
+	writeTestImages(t, dir, "green", 10, color.RGBA{20, 200, 20, 255})
+	writeTestImages(t, dir, "blue", 10, color.RGBA{20, 20, 200, 255})
+
+	set, err := neuralnet.NewImageSetBuilder().
+		WithPathPrefix(dir).
+		AddFolder("red", "red").
+		AddFolder("green", "green").
+		AddFolder("blue", "blue").
+		Normalize().
+		Build()
+	if err != nil {
+		t.Fatal(err)
+	}
+	return set
+}
+
+func trainTestModel(t *testing.T) (*neuralnet.TrainedModel, *neuralnet.ImageSet) {
+	t.Helper()
+	set := testImageSet(t)
+	hyperParams, err := neuralnet.NewHyperParametersBuilder().
+		AddLayers(neuralnet.ActivationFuncNameReLU, 4).
+		AddLayers(neuralnet.ActivationFuncNameSigmoid, 1).
+		SetIterations(50).
+		Build()
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	model, err := hyperParams.TrainModel(set, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return model, set
+}
+
+func TestSaveAndLoadModel(t *testing.T) {
+	model, _ := trainTestModel(t)
+
+	var saved bytes.Buffer
+	if err := model.Save(&saved); err != nil {
+		t.Fatal(err)
+	}
+	loaded, err := neuralnet.LoadModel(bytes.NewReader(saved.Bytes()))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if p := loaded.Preprocessing(); p.Width != 4 || p.Height != 4 || p.Normalizer == nil || len(p.Normalizer.Mean) != 48 {
+		t.Errorf("Expected preprocessing to be 4x4 and normalized, but got %+v", p)
+	}
+	if classes := loaded.Classes(); len(classes) != 2 || classes[0] != "red" || classes[1] != "blue" {
+		t.Errorf("Expected classes to be [red blue], but got %v", classes)
+	}
+
+	var resaved bytes.Buffer
+	if err := loaded.Save(&resaved); err != nil {
+		t.Fatal(err)
+	}
+	if !bytes.Equal(saved.Bytes(), resaved.Bytes()) {
+		t.Errorf("Expected a loaded model to save identically to the original")
+	}
+}
+
+func TestLoadModelUnsupportedVersion(t *testing.T) {
+	_, err := neuralnet.LoadModel(strings.NewReader(`{"format": "neuralnet-model", "version": 99}`))
+	if err == nil || !strings.Contains(err.Error(), "unsupported model format version") {
+		t.Errorf("Expected an unsupported version error, but got %v", err)
+	}
+}
+
+func TestLoadModelEmptyLayer(t *testing.T) {
+	for name, layer := range map[string]string{
+		"zero neurons": `{"neurons": 0, "inputs": 2, "activation": "sigmoid", "weights": [], "biases": []}`,
+		"zero inputs":  `{"neurons": 1, "inputs": 0, "activation": "sigmoid", "weights": [], "biases": [0]}`,
+	} {
+		file := `{"format": "neuralnet-model", "version": 1, "classes": ["a", "b"], "hyperParameters": ` +
+			`{"learningRate": 0.1, "iterations": 1}, "layers": [` + layer + `]}`
+		if _, err := neuralnet.LoadModel(strings.NewReader(file)); err == nil {
+			t.Errorf("Expected an error loading a layer with %s", name)
+		}
+	}
+}
+
+func TestLoadModelVersion1Normalized(t *testing.T) {
+	file := `{"format": "neuralnet-model", "version": 1, "classes": ["a", "b"], ` +
+		`"preprocessing": {"width": 1, "height": 1, "normalize": true}, ` +
+		`"hyperParameters": {"learningRate": 0.1, "iterations": 1}, ` +
+		`"layers": [{"neurons": 1, "inputs": 3, "activation": "sigmoid", "weights": [1, 2, 3], "biases": [0]}]}`
+	_, err := neuralnet.LoadModel(strings.NewReader(file))
+	if err == nil || !strings.Contains(err.Error(), "statistics") {
+		t.Errorf("Expected an error loading a normalized version 1 model without its statistics, but got %v", err)
+	}
+}
+
+func TestLoadModelVersion1Momentum(t *testing.T) {
+	for beta, optimizer := range map[string]string{
+		"0.9": `"name":"momentum","beta1":0.9`,
+		"0":   `"name":"gradient-descent"`,
+	} {
+		file := `{"format": "neuralnet-model", "version": 1, "classes": ["a", "b"], ` +
+			`"preprocessing": {"width": 1, "height": 1, "normalize": false}, ` +
+			`"hyperParameters": {"learningRate": 0.1, "iterations": 1, "momentumBeta": ` + beta + `}, ` +
+			`"layers": [{"neurons": 1, "inputs": 3, "activation": "sigmoid", "weights": [1, 2, 3], "biases": [0]}]}`
+		model, err := neuralnet.LoadModel(strings.NewReader(file))
+		if err != nil {
+			t.Fatal(err)
+		}
+		var saved bytes.Buffer
+		if err := model.Save(&saved); err != nil {
+			t.Fatal(err)
+		}
+		if !strings.Contains(saved.String(), `"optimizer":{`+optimizer+`}`) {
+			t.Errorf("Expected momentum beta %s to be saved as optimizer {%s}, but got %s",
+				beta, optimizer, saved.String())
+		}
+	}
+}
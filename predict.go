@@ -0,0 +1,356 @@
+package neuralnet
+
+import (
+	"errors"
+	"fmt"
+	"math"
+
+	"github.com/codehex/neuralnet/mx"
+)
+
+// Prediction is the output of the model for a single example
+type Prediction struct {
+	PathToImage string
+	// Label is the predicted class label, i.e. the one with the highest probability
+	Label string
+	// Labels are the predicted class labels of a multi-label model, i.e. the ones with a probability above 0.5, in
+	// which case Label is empty
+	Labels []string
+	// Probabilities holds the probability of each class, indexed in the same order as TrainedModel.Classes
+	Probabilities []float64
+	// Value is the output of a regression model, in which case Label and Probabilities are empty
+	Value float64
+}
+
+// Evaluation summarises how well the model predicts the labels of a set. Classification models report the
+// accuracy, and regression models the errors of the predicted values. For multi-label models an example is only
+// correct if all its labels are predicted, so Accuracy is the subset accuracy.
+type Evaluation struct {
+	Examples  uint
+	Correct   uint
+	Incorrect uint
+	Accuracy  float64
+	// Loss is the average loss of the predictions, excluding any regularization
+	Loss float64
+	// RMSE is the root mean squared error, MAE the mean absolute error and R2 the coefficient of determination
+	RMSE, MAE, R2 float64
+	// HammingLoss is the fraction of labels of a multi-label model predicted incorrectly, and Labels the metrics of
+	// each label, in the same order as TrainedModel.Classes
+	HammingLoss float64
+	Labels      []LabelMetrics
+	kind        labelKind
+}
+
+// LabelMetrics summarises how well a multi-label model predicts a single label
+type LabelMetrics struct {
+	Label string
+	// Precision is the fraction of the predictions of the label that are correct, and Recall the fraction of the
+	// examples with the label that are predicted. Both are 0 if there is nothing to divide by.
+	Precision, Recall float64
+}
+
+func (e Evaluation) String() string {
+	switch e.kind {
+	case targetValues:
+		return fmt.Sprintf("rmse: %.5g, mae: %.5g, r2: %.5g, loss: %.5g", e.RMSE, e.MAE, e.R2, e.Loss)
+	case multipleLabels:
+		return fmt.Sprintf("subset accuracy: %.5g, hamming loss: %.5g, loss: %.5g", e.Accuracy, e.HammingLoss, e.Loss)
+	}
+	return fmt.Sprintf("correct: %d, incorrect: %d, accuracy: %.5g, loss: %.5g", e.Correct, e.Incorrect, e.Accuracy, e.Loss)
+}
+
+// Predict runs the examples of the set through the model, returning a prediction per example
+func (t *TrainedModel) Predict(set Dataset) ([]Prediction, error) {
+	AL, err := t.outputActivations(set)
+	if err != nil {
+		return nil, err
+	}
+	return t.predictions(set, AL), nil
+}
+
+// Evaluate compares the predictions of the model with the labels of the set
+func (t *TrainedModel) Evaluate(set Dataset) (Evaluation, error) {
+	AL, err := t.outputActivations(set)
+	if err != nil {
+		return Evaluation{}, err
+	}
+	if kind := datasetKind(set); kind != t.kind {
+		return Evaluation{}, fmt.Errorf("set has %s, but the model was trained with %s", kind, t.kind)
+	}
+	predictions := t.predictions(set, AL)
+	switch t.kind {
+	case targetValues:
+		return t.evaluateRegression(set, predictions), nil
+	case multipleLabels:
+		return t.evaluateMultiLabel(set, AL)
+	}
+
+	eval := Evaluation{Examples: uint(len(predictions))}
+	rows, _ := AL.Dims()
+	for i, prediction := range predictions {
+		// Compare labels rather than indices, as the set may order its classes differently to the training set
+		labels := exampleLabels(set, i)
+		if len(labels) != 1 {
+			return Evaluation{}, fmt.Errorf("%s does not have a class label", describeExample(set, i))
+		}
+		label := labels[0]
+		classIndex := t.classIndex(label)
+		if classIndex < 0 {
+			return Evaluation{}, fmt.Errorf("label '%s' of %s is not a class of the model", label, describeExample(set, i))
+		}
+		if prediction.Label == label {
+			eval.Correct++
+		} else {
+			eval.Incorrect++
+		}
+		for row := 0; row < rows; row++ {
+			eval.Loss += t.hyper.loss.cost(AL.At(row, i), t.target(classIndex, row, rows))
+		}
+	}
+	if eval.Examples > 0 {
+		eval.Accuracy = float64(eval.Correct) / float64(eval.Examples)
+		eval.Loss /= float64(eval.Examples)
+	}
+	return eval, nil
+}
+
+// evaluateRegression compares the predicted values with the targets of the set
+func (t *TrainedModel) evaluateRegression(set Dataset, predictions []Prediction) Evaluation {
+	eval := Evaluation{Examples: uint(len(predictions)), kind: targetValues}
+	if eval.Examples == 0 {
+		return eval
+	}
+	Y := set.Y()
+	mean := 0.0
+	for i := range predictions {
+		mean += Y.At(0, i)
+	}
+	mean /= float64(eval.Examples)
+
+	squared, total := 0.0, 0.0
+	for i, prediction := range predictions {
+		target := Y.At(0, i)
+		eval.Loss += t.hyper.loss.cost(prediction.Value, target)
+		eval.MAE += math.Abs(prediction.Value - target)
+		squared += (prediction.Value - target) * (prediction.Value - target)
+		total += (target - mean) * (target - mean)
+	}
+	eval.Loss /= float64(eval.Examples)
+	eval.MAE /= float64(eval.Examples)
+	eval.RMSE = math.Sqrt(squared / float64(eval.Examples))
+	// R2 is undefined when all the targets are the same, leave it as 0 rather than dividing by 0
+	if total > 0 {
+		eval.R2 = 1 - squared/total
+	}
+	return eval
+}
+
+// evaluateMultiLabel compares each output of the model with the labels of the set
+func (t *TrainedModel) evaluateMultiLabel(set Dataset, AL mx.MatrixViewable) (Evaluation, error) {
+	eval := Evaluation{Examples: set.NumberOfExamples(), kind: multipleLabels}
+	truePositives := make([]uint, len(t.classes))
+	falsePositives := make([]uint, len(t.classes))
+	falseNegatives := make([]uint, len(t.classes))
+	wrongLabels := 0
+	for i := 0; i < int(eval.Examples); i++ {
+		expected := make([]bool, len(t.classes))
+		for _, label := range exampleLabels(set, i) {
+			classIndex := t.classIndex(label)
+			if classIndex < 0 {
+				return Evaluation{}, fmt.Errorf("label '%s' of %s is not a class of the model", label, describeExample(set, i))
+			}
+			expected[classIndex] = true
+		}
+
+		exact := true
+		for k := range t.classes {
+			predicted := AL.At(k, i) > 0.5
+			switch {
+			case predicted && expected[k]:
+				truePositives[k]++
+			case predicted:
+				falsePositives[k]++
+			case expected[k]:
+				falseNegatives[k]++
+			}
+			if predicted != expected[k] {
+				exact = false
+				wrongLabels++
+			}
+			target := 0.0
+			if expected[k] {
+				target = 1
+			}
+			eval.Loss += t.hyper.loss.cost(AL.At(k, i), target)
+		}
+		if exact {
+			eval.Correct++
+		} else {
+			eval.Incorrect++
+		}
+	}
+
+	for k, label := range t.classes {
+		metrics := LabelMetrics{Label: label}
+		if predicted := truePositives[k] + falsePositives[k]; predicted > 0 {
+			metrics.Precision = float64(truePositives[k]) / float64(predicted)
+		}
+		if actual := truePositives[k] + falseNegatives[k]; actual > 0 {
+			metrics.Recall = float64(truePositives[k]) / float64(actual)
+		}
+		eval.Labels = append(eval.Labels, metrics)
+	}
+	if eval.Examples > 0 {
+		eval.Accuracy = float64(eval.Correct) / float64(eval.Examples)
+		eval.Loss /= float64(eval.Examples)
+		eval.HammingLoss = float64(wrongLabels) / float64(eval.Examples*uint(len(t.classes)))
+	}
+	return eval, nil
+}
+
+// predictions converts the output of the model for the examples of the set into predictions
+func (t *TrainedModel) predictions(set Dataset, AL mx.MatrixViewable) []Prediction {
+	images, _ := set.(*ImageSet)
+	predictions := make([]Prediction, set.NumberOfExamples())
+	for i := range predictions {
+		pathToImage := ""
+		if images != nil {
+			pathToImage = images.entries[i].pathToImage
+		}
+		switch t.kind {
+		case targetValues:
+			predictions[i] = Prediction{PathToImage: pathToImage, Value: AL.At(0, i)}
+			continue
+		case multipleLabels:
+			predictions[i] = Prediction{
+				PathToImage:   pathToImage,
+				Labels:        t.predictLabels(AL, i),
+				Probabilities: t.classProbabilities(AL, i),
+			}
+			continue
+		}
+		predictions[i] = Prediction{
+			PathToImage:   pathToImage,
+			Label:         t.classes[t.predictClass(AL, i)],
+			Probabilities: t.classProbabilities(AL, i),
+		}
+	}
+	return predictions
+}
+
+// target returns the expected output of the given row of the last layer for an example of the given class
+func (t *TrainedModel) target(classIndex, row, rows int) float64 {
+	if rows == 1 {
+		return float64(classIndex)
+	}
+	if row == classIndex {
+		return 1
+	}
+	return 0
+}
+
+// exampleLabels returns the class labels of the example in the given column of the labels of the set
+func exampleLabels(set Dataset, column int) []string {
+	Y, classes := set.Y(), set.Classes()
+	rows, _ := Y.Dims()
+	var labels []string
+	switch {
+	case set.MultiLabel():
+		for k := 0; k < rows && k < len(classes); k++ {
+			if Y.At(k, column) > 0.5 {
+				labels = append(labels, classes[k])
+			}
+		}
+	case rows == 1:
+		if index := int(Y.At(0, column)); index >= 0 && index < len(classes) {
+			labels = append(labels, classes[index])
+		}
+	default:
+		if index := mx.ColumnArgMax(Y, column); index < len(classes) {
+			labels = append(labels, classes[index])
+		}
+	}
+	return labels
+}
+
+// describeExample identifies the example in the given column of the set in error messages
+func describeExample(set Dataset, column int) string {
+	if images, ok := set.(*ImageSet); ok {
+		return images.entries[column].pathToImage
+	}
+	return fmt.Sprintf("example %d", column)
+}
+
+// outputActivations forward propagates the examples of the set, returning the activations of the last layer
+func (t *TrainedModel) outputActivations(set Dataset) (mx.Matrix, error) {
+	if datasetOrNil(set) == nil {
+		return mx.Matrix{}, errors.New("set cannot be nil")
+	}
+	_, inputs := t.params.W[1].Dims()
+	if set.FeatureCount() != uint(inputs) {
+		return mx.Matrix{}, fmt.Errorf("set has %d features per example, but the model expects %d", set.FeatureCount(), inputs)
+	}
+
+	nodes := t.hyper.generateNodes(set.FeatureCount())
+	cache := t.hyper.initCache(nodes, set.NumberOfExamples())
+	for i := 1; i < len(nodes); i++ {
+		t.hyper.forwardPropagation(set.X(), cache, t.params, i, nil)
+	}
+	return cache[len(nodes)-1].A, nil
+}
+
+// classProbabilities returns the probability of each class for the example in the given column of the output
+func (t *TrainedModel) classProbabilities(AL mx.MatrixViewable, column int) []float64 {
+	if t.kind == multipleLabels || t.hyper.outputLayer().actFuncLabel == ActivationFuncNameSoftmax {
+		rows, _ := AL.Dims()
+		probabilities := make([]float64, rows)
+		for i := range probabilities {
+			probabilities[i] = AL.At(i, column)
+		}
+		return probabilities
+	}
+	// A single neuron gives the probability of the second (positive) class. Outputs that aren't probabilities are
+	// clipped, with the decision threshold of the loss mapped to 0.5.
+	p := AL.At(0, column)
+	if t.hyper.loss.Name == LossNameHinge {
+		p = (p + 1) / 2
+	}
+	p = math.Min(math.Max(p, 0), 1)
+	return []float64{1 - p, p}
+}
+
+// predictLabels returns the labels with a probability above 0.5 for the example in the given column of the output
+func (t *TrainedModel) predictLabels(AL mx.MatrixViewable, column int) []string {
+	labels := []string{}
+	for k, label := range t.classes {
+		if AL.At(k, column) > 0.5 {
+			labels = append(labels, label)
+		}
+	}
+	return labels
+}
+
+// predictClass returns the index of the predicted class for the example in the given column of the output
+func (t *TrainedModel) predictClass(AL mx.MatrixViewable, column int) int {
+	if t.hyper.outputLayer().actFuncLabel == ActivationFuncNameSoftmax {
+		return mx.ColumnArgMax(AL, column)
+	}
+	threshold := 0.5
+	if t.hyper.loss.Name == LossNameHinge {
+		// Hinge loss separates the classes at 0, with labels of -1 and 1
+		threshold = 0
+	}
+	if AL.At(0, column) > threshold {
+		return 1
+	}
+	return 0
+}
+
+func (t *TrainedModel) classIndex(label string) int {
+	for index, class := range t.classes {
+		if class == label {
+			return index
+		}
+	}
+	return -1
+}
@@ -0,0 +1,113 @@
+package neuralnet_test
+
+import (
+	"image/color"
+	"strings"
+	"testing"
+
+	"github.com/codehex/neuralnet"
+)
+
+func TestPredict(t *testing.T) {
+	model, set := trainTestModel(t)
+
+	predictions, err := model.Predict(set)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(predictions) != int(set.NumberOfExamples()) {
+		t.Fatalf("Expected %d predictions, but got %d", set.NumberOfExamples(), len(predictions))
+	}
+	for _, prediction := range predictions {
+		if !strings.HasSuffix(prediction.PathToImage, ".jpg") {
+			t.Errorf("Expected prediction to reference the source image, but got %s", prediction.PathToImage)
+		}
+		if len(prediction.Probabilities) != 2 {
+			t.Fatalf("Expected 2 probabilities, but got %v", prediction.Probabilities)
+		}
+		if sum := prediction.Probabilities[0] + prediction.Probabilities[1]; sum < 0.999 || sum > 1.001 {
+			t.Errorf("Expected probabilities to sum to 1, but got %v", sum)
+		}
+		if prediction.Label != "red" && prediction.Label != "blue" {
+			t.Errorf("Expected label to be red or blue, but got %s", prediction.Label)
+		}
+	}
+
+	eval, err := model.Evaluate(set)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if eval.Examples != 20 || eval.Correct+eval.Incorrect != 20 {
+		t.Errorf("Expected 20 evaluated examples, but got %+v", eval)
+	}
+}
+
+func TestPredictSoftmax(t *testing.T) {
+	set := testThreeClassImageSet(t)
+	hyperParams, err := neuralnet.NewHyperParametersBuilder().
+		AddLayers(neuralnet.ActivationFuncNameReLU, 4).
+		AddLayers(neuralnet.ActivationFuncNameSoftmax, 3).
+		SetIterations(100).
+		SetSeed(1).
+		Build()
+	if err != nil {
+		t.Fatal(err)
+	}
+	model, err := hyperParams.TrainModel(set, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if classes := model.Classes(); strings.Join(classes, " ") != "red green blue" {
+		t.Errorf("Expected classes to be [red green blue], but got %v", classes)
+	}
+	epochs := model.History().Epochs
+	if first, last := epochs[0].Loss, epochs[len(epochs)-1].Loss; !(last < first) {
+		t.Errorf("Expected the categorical cross-entropy to decrease, but it went from %v to %v", first, last)
+	}
+
+	predictions, err := model.Predict(set)
+	if err != nil {
+		t.Fatal(err)
+	}
+	for _, prediction := range predictions {
+		if len(prediction.Probabilities) != 3 {
+			t.Fatalf("Expected 3 probabilities, but got %v", prediction.Probabilities)
+		}
+		sum := prediction.Probabilities[0] + prediction.Probabilities[1] + prediction.Probabilities[2]
+		if sum < 0.999 || sum > 1.001 {
+			t.Errorf("Expected probabilities to sum to 1, but got %v", sum)
+		}
+		// The images of each class are in a folder named after it
+		if !strings.Contains(prediction.PathToImage, "/"+prediction.Label+"/") {
+			t.Errorf("Expected %s to be predicted as the class of its folder, but got %s (%v)",
+				prediction.PathToImage, prediction.Label, prediction.Probabilities)
+		}
+	}
+
+	eval, err := model.Evaluate(set)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if eval.Examples != 30 || eval.Accuracy != 1 {
+		t.Errorf("Expected the model to classify all 30 examples, but got %v", eval)
+	}
+}
+
+func TestPredictFeatureCountMismatch(t *testing.T) {
+	model, _ := trainTestModel(t)
+
+	dir := t.TempDir()
+	writeTestImages(t, dir, "red", 1, color.RGBA{200, 20, 20, 255})
+	set, err := neuralnet.NewImageSetBuilder().
+		WithPathPrefix(dir).
+		AddFolder("red", "red").
+		ResizeImages(8, 8).
+		Build()
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if _, err := model.Predict(set); err == nil {
+		t.Errorf("Expected an error when the set has a different number of features to the model")
+	}
+}
@@ -0,0 +1,108 @@
+package neuralnet_test
+
+import (
+	"bytes"
+	"fmt"
+	"image/color"
+	"path"
+	"testing"
+
+	"github.com/codehex/neuralnet"
+)
+
+// testRegressionSet builds a normalized set of grey images, whose target is their brightness
+func testRegressionSet(t *testing.T) *neuralnet.ImageSet {
+	t.Helper()
+	dir := t.TempDir()
+	builder := neuralnet.NewImageSetBuilder().WithPathPrefix(dir)
+	for _, brightness := range []uint8{20, 80, 140, 200} {
+		folder := fmt.Sprint(brightness)
+		writeTestImages(t, dir, folder, 5, color.RGBA{brightness, brightness, brightness, 255})
+		targets := map[string]float64{}
+		for n := 0; n < 5; n++ {
+			targets[fmt.Sprintf("%d.jpg", n)] = float64(brightness) / 100
+		}
+		builder = builder.AddFolderWithTargets(folder, targets)
+	}
+	set, err := builder.Normalize().Build()
+	if err != nil {
+		t.Fatal(err)
+	}
+	return set
+}
+
+func TestTrainRegressionModel(t *testing.T) {
+	set := testRegressionSet(t)
+	hyperParams, err := neuralnet.NewHyperParametersBuilder().
+		AddLayers(neuralnet.ActivationFuncNameReLU, 4).
+		AddLayers(neuralnet.ActivationFuncNameLinear, 1).
+		SetLoss(neuralnet.Loss{Name: neuralnet.LossNameMeanSquaredError}).
+		UseAdam(0.9, 0.999, 1e-8).
+		SetIterations(300).
+		SetSeed(1).
+		Build()
+	if err != nil {
+		t.Fatal(err)
+	}
+	model, err := hyperParams.TrainModel(set, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !model.Regression() {
+		t.Fatalf("Expected a regression model")
+	}
+
+	predictions, err := model.Predict(set)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if predictions[0].Label != "" || predictions[0].Probabilities != nil {
+		t.Errorf("Expected regression predictions to only have a value, but got %+v", predictions[0])
+	}
+
+	eval, err := model.Evaluate(set)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if eval.R2 < 0.9 || eval.RMSE > 0.3 {
+		t.Errorf("Expected the model to fit the targets, but got %v", eval)
+	}
+
+	var saved bytes.Buffer
+	if err := model.Save(&saved); err != nil {
+		t.Fatal(err)
+	}
+	loaded, err := neuralnet.LoadModel(&saved)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if loadedEval, err := loaded.Evaluate(set); err != nil || loadedEval.RMSE != eval.RMSE || loadedEval.R2 != eval.R2 {
+		t.Errorf("Expected the loaded model to evaluate like the original, but got %v (%v)", loadedEval, err)
+	}
+}
+
+func TestTrainRegressionModelWithClassificationLoss(t *testing.T) {
+	set := testRegressionSet(t)
+	hyperParams, err := neuralnet.NewHyperParametersBuilder().
+		AddLayers(neuralnet.ActivationFuncNameSigmoid, 1).
+		Build()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, err := hyperParams.TrainModel(set, nil); err == nil {
+		t.Errorf("Expected an error when training on targets with binary cross-entropy")
+	}
+}
+
+func TestImageSetMixingLabelsAndTargets(t *testing.T) {
+	dir := t.TempDir()
+	writeTestImages(t, dir, "grey", 1, color.RGBA{100, 100, 100, 255})
+	_, err := neuralnet.NewImageSetBuilder().
+		WithPathPrefix(dir).
+		AddFolder("grey", "grey").
+		AddImageWithTarget(path.Join(dir, "grey", "0.jpg"), 0.5).
+		Build()
+	if err == nil {
+		t.Errorf("Expected an error when adding targets to a set with class labels")
+	}
+}
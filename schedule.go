@@ -0,0 +1,124 @@
+package neuralnet
+
+import (
+	"errors"
+	"fmt"
+	"math"
+)
+
+type ScheduleName string
+
+const (
+	ScheduleNameConstant         ScheduleName = "constant"
+	ScheduleNameStepDecay        ScheduleName = "step-decay"
+	ScheduleNameExponentialDecay ScheduleName = "exponential-decay"
+	ScheduleNameCosineAnnealing  ScheduleName = "cosine-annealing"
+	ScheduleNameInverseTimeDecay ScheduleName = "inverse-time-decay"
+	ScheduleNameCustom           ScheduleName = "custom"
+)
+
+// LearningRateSchedule returns the learning rate to use for an epoch (iteration over the training set) and step
+// (number of mini-batches processed since training started), both counted from 0
+type LearningRateSchedule func(epoch, step int) float64
+
+// scheduleConfig holds the learning rate schedule chosen on the HyperParametersBuilder
+type scheduleConfig struct {
+	name            ScheduleName
+	decayRate       float64
+	decayInterval   uint
+	minLearningRate float64
+	perBatch        bool
+	warmup          uint
+	custom          LearningRateSchedule
+}
+
+func (c scheduleConfig) validate(learningRate float64) error {
+	switch c.name {
+	case ScheduleNameConstant:
+	case ScheduleNameStepDecay:
+		if c.decayInterval == 0 {
+			return errors.New("step decay interval must be greater than 0")
+		}
+		if c.decayRate <= 0 || c.decayRate > 1 {
+			return errors.New("step decay factor must be between 0 and 1")
+		}
+	case ScheduleNameExponentialDecay:
+		if c.decayRate <= 0 || c.decayRate > 1 {
+			return errors.New("exponential decay rate must be between 0 and 1")
+		}
+	case ScheduleNameCosineAnnealing:
+		if c.minLearningRate < 0 || c.minLearningRate > learningRate {
+			return errors.New("cosine annealing minimum learning rate must be between 0 and the learning rate")
+		}
+	case ScheduleNameInverseTimeDecay:
+		if c.decayRate < 0 {
+			return errors.New("inverse time decay rate cannot be negative")
+		}
+	case ScheduleNameCustom:
+		if c.custom == nil {
+			return errors.New("custom learning rate schedule cannot be nil")
+		}
+	default:
+		return fmt.Errorf("unknown learning rate schedule '%s'", c.name)
+	}
+	return nil
+}
+
+// learningRate calculates the learning rate for the given epoch and step. Built in schedules decay the base
+// learning rate once per epoch, or once per step if perBatch is set, and total is the number of epochs or steps
+// the training will run for.
+func (c scheduleConfig) learningRate(base float64, epoch, step, total int) float64 {
+	if c.name == ScheduleNameCustom {
+		return c.withWarmup(c.custom(epoch, step), epoch, step)
+	}
+
+	t := epoch
+	if c.perBatch {
+		t = step
+	}
+	rate := base
+	switch c.name {
+	case ScheduleNameStepDecay:
+		rate = base * math.Pow(c.decayRate, float64(t/int(c.decayInterval)))
+	case ScheduleNameExponentialDecay:
+		rate = base * math.Pow(c.decayRate, float64(t))
+	case ScheduleNameCosineAnnealing:
+		progress := float64(t) / math.Max(float64(total-1), 1)
+		rate = c.minLearningRate + (base-c.minLearningRate)*(1+math.Cos(math.Pi*progress))/2
+	case ScheduleNameInverseTimeDecay:
+		rate = base / (1 + c.decayRate*float64(t))
+	}
+	return c.withWarmup(rate, epoch, step)
+}
+
+// withWarmup scales the rate up linearly over the warmup period
+func (c scheduleConfig) withWarmup(rate float64, epoch, step int) float64 {
+	t := epoch
+	if c.perBatch {
+		t = step
+	}
+	if t < int(c.warmup) {
+		return rate * float64(t+1) / float64(c.warmup)
+	}
+	return rate
+}
+
+func (c scheduleConfig) String() string {
+	unit := "epoch"
+	if c.perBatch {
+		unit = "batch"
+	}
+	description := string(c.name)
+	switch c.name {
+	case ScheduleNameStepDecay:
+		description = fmt.Sprintf("%s (factor %.5g every %d %s(es))", c.name, c.decayRate, c.decayInterval, unit)
+	case ScheduleNameExponentialDecay, ScheduleNameInverseTimeDecay:
+		description = fmt.Sprintf("%s (rate %.5g per %s)", c.name, c.decayRate, unit)
+	case ScheduleNameCosineAnnealing:
+		description = fmt.Sprintf("%s (minimum %.5g, per %s)", c.name, c.minLearningRate, unit)
+	}
+	if c.warmup > 0 {
+		description += fmt.Sprintf(", linear warmup over %d %s(es)", c.warmup, unit)
+	}
+	return description
+}
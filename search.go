@@ -0,0 +1,348 @@
+package neuralnet
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"math"
+	"math/rand"
+	"sort"
+	"strings"
+	"sync"
+)
+
+// SearchSpace defines the candidate hyperparameters of a search, as the choices or ranges of values applied to a
+// base builder. The base builder defines the output layer and any setting that isn't searched.
+type SearchSpace struct {
+	base       HyperParametersBuilder
+	dimensions []searchDimension
+}
+
+type searchDimension struct {
+	name string
+	// choices holds the values of the dimension, or is nil if it is a range that can only be sampled
+	choices []searchValue
+	sample  func(r *rand.Rand) searchValue
+}
+
+type searchValue struct {
+	description string
+	apply       func(builder HyperParametersBuilder) HyperParametersBuilder
+}
+
+func NewSearchSpace(base HyperParametersBuilder) SearchSpace {
+	return SearchSpace{base: base}
+}
+
+func (space SearchSpace) addChoices(name string, choices []searchValue) SearchSpace {
+	dimension := searchDimension{name: name, choices: choices}
+	dimension.sample = func(r *rand.Rand) searchValue {
+		return choices[r.Intn(len(choices))]
+	}
+	// Copy the dimensions, so spaces branched from the same parent don't share them
+	space.dimensions = append(append([]searchDimension{}, space.dimensions...), dimension)
+	return space
+}
+
+func (space SearchSpace) addRange(name string, sample func(r *rand.Rand) searchValue) SearchSpace {
+	space.dimensions = append(append([]searchDimension{}, space.dimensions...), searchDimension{name: name, sample: sample})
+	return space
+}
+
+func (space SearchSpace) LearningRates(rates ...float64) SearchSpace {
+	choices := make([]searchValue, len(rates))
+	for i, rate := range rates {
+		choices[i] = learningRateValue(rate)
+	}
+	return space.addChoices("learning rate", choices)
+}
+
+// LearningRateRange samples learning rates between min and max on a log scale, for random search only
+func (space SearchSpace) LearningRateRange(min, max float64) SearchSpace {
+	return space.addRange("learning rate", func(r *rand.Rand) searchValue {
+		return learningRateValue(math.Exp(math.Log(min) + r.Float64()*(math.Log(max)-math.Log(min))))
+	})
+}
+
+func learningRateValue(rate float64) searchValue {
+	return searchValue{fmt.Sprintf("%.5g", rate), func(builder HyperParametersBuilder) HyperParametersBuilder {
+		return builder.SetLearningRate(rate)
+	}}
+}
+
+func (space SearchSpace) RegularizationFactors(factors ...float64) SearchSpace {
+	choices := make([]searchValue, len(factors))
+	for i, factor := range factors {
+		choices[i] = regularizationValue(factor)
+	}
+	return space.addChoices("regularization factor", choices)
+}
+
+// RegularizationFactorRange samples regularization factors uniformly between min and max, for random search only
+func (space SearchSpace) RegularizationFactorRange(min, max float64) SearchSpace {
+	return space.addRange("regularization factor", func(r *rand.Rand) searchValue {
+		return regularizationValue(min + r.Float64()*(max-min))
+	})
+}
+
+func regularizationValue(factor float64) searchValue {
+	return searchValue{fmt.Sprintf("%.5g", factor), func(builder HyperParametersBuilder) HyperParametersBuilder {
+		return builder.SetRegularizationFactor(factor)
+	}}
+}
+
+func (space SearchSpace) DropoutKeepProbabilities(probabilities ...float64) SearchSpace {
+	choices := make([]searchValue, len(probabilities))
+	for i, probability := range probabilities {
+		choices[i] = keepProbabilityValue(probability)
+	}
+	return space.addChoices("dropout keep probability", choices)
+}
+
+// DropoutKeepProbabilityRange samples dropout keep probabilities uniformly between min and max, for random search
+// only
+func (space SearchSpace) DropoutKeepProbabilityRange(min, max float64) SearchSpace {
+	return space.addRange("dropout keep probability", func(r *rand.Rand) searchValue {
+		return keepProbabilityValue(min + r.Float64()*(max-min))
+	})
+}
+
+func keepProbabilityValue(probability float64) searchValue {
+	return searchValue{fmt.Sprintf("%.5g", probability), func(builder HyperParametersBuilder) HyperParametersBuilder {
+		return builder.SetDropoutKeepProbability(probability)
+	}}
+}
+
+func (space SearchSpace) MiniBatchSizes(sizes ...uint) SearchSpace {
+	choices := make([]searchValue, len(sizes))
+	for i, size := range sizes {
+		size := size
+		choices[i] = searchValue{fmt.Sprint(size), func(builder HyperParametersBuilder) HyperParametersBuilder {
+			return builder.SetMiniBatchSize(size)
+		}}
+	}
+	return space.addChoices("mini-batch size", choices)
+}
+
+// HiddenLayers adds a choice of hidden layers before the layers of the base builder, each choice giving the
+// number of neurons of every hidden layer. An empty choice adds no hidden layers.
+func (space SearchSpace) HiddenLayers(a ActivationFuncName, choices ...[]uint) SearchSpace {
+	values := make([]searchValue, len(choices))
+	for i, neurons := range choices {
+		neurons := neurons
+		values[i] = searchValue{fmt.Sprintf("%s %v", a, neurons), func(builder HyperParametersBuilder) HyperParametersBuilder {
+			layers := builder.params.layers
+			builder.params.layers = nil
+			builder = builder.AddLayers(a, neurons...)
+			builder.params.layers = append(builder.params.layers, layers...)
+			return builder
+		}}
+	}
+	return space.addChoices("hidden layers", values)
+}
+
+type SearchMethod string
+
+const (
+	// SearchGrid tries every combination of the choices of the search space
+	SearchGrid SearchMethod = "grid"
+	// SearchRandom tries a number of combinations sampled from the search space
+	SearchRandom SearchMethod = "random"
+)
+
+// SearchOptions configures how SearchHyperParameters picks and scores the candidates
+type SearchOptions struct {
+	Method SearchMethod
+	// Candidates is the number of candidates sampled by random search
+	Candidates uint
+	// Seed seeds the sampling of random search and the folds of cross-validation
+	Seed int64
+	// Parallelism is the number of candidates trained at the same time, 0 or 1 trains them one after the other
+	Parallelism uint
+	// Folds scores the candidates by cross-validating them on the training set with that many folds, rather than
+	// against a validation set
+	Folds      uint
+	Stratified bool
+}
+
+// SearchCandidate is a candidate of a search, with its score
+type SearchCandidate struct {
+	HyperParameters HyperParameters
+	// Description describes the value picked for each dimension of the search space
+	Description string
+	// Loss is the score of the candidate: its loss on the validation set, or its mean loss across the folds
+	Loss float64
+	// Evaluation is the evaluation on the validation set, if the candidate wasn't cross-validated
+	Evaluation Evaluation
+	// CrossValidation is the result of the cross-validation, if the candidate was cross-validated
+	CrossValidation CrossValidationResult
+	// Model is the model trained with the candidate, nil if it was cross-validated
+	Model *TrainedModel
+	// Iterations is the number of iterations the model was trained for
+	Iterations uint
+}
+
+// SearchResult holds the candidates of a search, ranked from the lowest to the highest loss
+type SearchResult struct {
+	Leaderboard []SearchCandidate
+	Best        HyperParameters
+}
+
+func (r SearchResult) String() string {
+	var b strings.Builder
+	for i, candidate := range r.Leaderboard {
+		fmt.Fprintf(&b, "%d. loss: %.5g  %s\n", i+1, candidate.Loss, candidate.Description)
+	}
+	return b.String()
+}
+
+// SearchHyperParameters trains a model for every candidate of the search space, see SearchHyperParametersContext
+func SearchHyperParameters(space SearchSpace, trainingDataSet, validationDataSet Dataset, options SearchOptions) (SearchResult, error) {
+	return SearchHyperParametersContext(context.Background(), space, trainingDataSet, validationDataSet, options)
+}
+
+// SearchHyperParametersContext trains a model for every candidate of the search space, scoring them by their loss on
+// the validation set, or cross-validating them on the training set if options.Folds is set (the validation set must
+// then be nil). Checkpointing is disabled for the candidates, and callbacks are called concurrently if candidates are
+// trained in parallel.
+func SearchHyperParametersContext(ctx context.Context, space SearchSpace, trainingDataSet, validationDataSet Dataset, options SearchOptions) (SearchResult, error) {
+	validationDataSet = datasetOrNil(validationDataSet)
+	if options.Folds > 0 && validationDataSet != nil {
+		return SearchResult{}, errors.New("search uses either cross-validation or a validation set, not both")
+	}
+	if options.Folds == 0 && validationDataSet == nil {
+		return SearchResult{}, errors.New("search requires a validation set or a number of folds")
+	}
+	candidates, err := space.candidates(options)
+	if err != nil {
+		return SearchResult{}, err
+	}
+	if len(candidates) == 0 {
+		return SearchResult{}, errors.New("search space has no candidates")
+	}
+
+	err = runConcurrently(len(candidates), options.Parallelism, func(i int) error {
+		candidate := &candidates[i]
+		if options.Folds > 0 {
+			result, err := CrossValidateContext(ctx, candidate.HyperParameters, trainingDataSet, options.Folds,
+				CrossValidationOptions{Stratified: options.Stratified, Seed: options.Seed})
+			if err != nil {
+				return fmt.Errorf("candidate %s: %w", candidate.Description, err)
+			}
+			candidate.CrossValidation, candidate.Loss = result, result.Loss.Mean
+			return nil
+		}
+		model, err := candidate.HyperParameters.TrainModelContext(ctx, trainingDataSet, validationDataSet)
+		if err != nil {
+			return fmt.Errorf("candidate %s: %w", candidate.Description, err)
+		}
+		return candidate.evaluate(model, validationDataSet)
+	})
+	if err != nil {
+		return SearchResult{}, err
+	}
+
+	sort.SliceStable(candidates, func(i, j int) bool {
+		return rankingLoss(candidates[i].Loss) < rankingLoss(candidates[j].Loss)
+	})
+	return SearchResult{Leaderboard: candidates, Best: candidates[0].HyperParameters}, nil
+}
+
+// evaluate scores the candidate by the loss of its model on the validation set
+func (candidate *SearchCandidate) evaluate(model *TrainedModel, validationDataSet Dataset) error {
+	eval, err := model.Evaluate(validationDataSet)
+	if err != nil {
+		return fmt.Errorf("candidate %s: %w", candidate.Description, err)
+	}
+	candidate.Model, candidate.Evaluation, candidate.Loss = model, eval, eval.Loss
+	candidate.Iterations = uint(len(model.History().Epochs))
+	return nil
+}
+
+// candidates builds the hyperparameters of every candidate the search tries
+func (space SearchSpace) candidates(options SearchOptions) ([]SearchCandidate, error) {
+	for _, dimension := range space.dimensions {
+		if dimension.choices != nil && len(dimension.choices) == 0 {
+			return nil, fmt.Errorf("search space has no choices of %s", dimension.name)
+		}
+	}
+	var combinations [][]searchValue
+	switch options.Method {
+	case SearchGrid:
+		combinations = [][]searchValue{nil}
+		for _, dimension := range space.dimensions {
+			if dimension.choices == nil {
+				return nil, fmt.Errorf("grid search requires choices of %s rather than a range", dimension.name)
+			}
+			var next [][]searchValue
+			for _, combination := range combinations {
+				for _, choice := range dimension.choices {
+					next = append(next, append(append([]searchValue{}, combination...), choice))
+				}
+			}
+			combinations = next
+		}
+	case SearchRandom:
+		if options.Candidates == 0 {
+			return nil, errors.New("random search requires a number of candidates greater than 0")
+		}
+		rng := rand.New(rand.NewSource(options.Seed))
+		for i := uint(0); i < options.Candidates; i++ {
+			combination := make([]searchValue, len(space.dimensions))
+			for d, dimension := range space.dimensions {
+				combination[d] = dimension.sample(rng)
+			}
+			combinations = append(combinations, combination)
+		}
+	default:
+		return nil, fmt.Errorf("unknown search method '%s'", options.Method)
+	}
+
+	candidates := make([]SearchCandidate, len(combinations))
+	for i, combination := range combinations {
+		builder := space.base
+		descriptions := make([]string, len(combination))
+		for d, value := range combination {
+			builder = value.apply(builder)
+			descriptions[d] = space.dimensions[d].name + ": " + value.description
+		}
+		candidates[i].Description = strings.Join(descriptions, ", ")
+		h, err := builder.Build()
+		if err != nil {
+			return nil, fmt.Errorf("candidate %s: %w", candidates[i].Description, err)
+		}
+		h.checkpoint = checkpointConfig{}
+		candidates[i].HyperParameters = h
+	}
+	return candidates, nil
+}
+
+// rankingLoss ranks candidates whose loss diverged last
+func rankingLoss(loss float64) float64 {
+	if math.IsNaN(loss) {
+		return math.Inf(1)
+	}
+	return loss
+}
+
+// runConcurrently calls f for each index from 0 to n-1, with at most parallelism calls running at the same time,
+// and returns their errors joined
+func runConcurrently(n int, parallelism uint, f func(i int) error) error {
+	if parallelism == 0 {
+		parallelism = 1
+	}
+	errs := make([]error, n)
+	limit := make(chan struct{}, parallelism)
+	var wg sync.WaitGroup
+	for i := 0; i < n; i++ {
+		wg.Add(1)
+		go func(i int) {
+			defer wg.Done()
+			limit <- struct{}{}
+			defer func() { <-limit }()
+			errs[i] = f(i)
+		}(i)
+	}
+	wg.Wait()
+	return errors.Join(errs...)
+}
@@ -0,0 +1,104 @@
+package neuralnet_test
+
+import (
+	"testing"
+
+	"github.com/codehex/neuralnet"
+)
+
+func searchTestSet(t *testing.T) *neuralnet.FeatureSet {
+	var features [][]float64
+	var labels []string
+	for i := 0; i < 10; i++ {
+		offset := float64(i%5) * 0.1
+		features = append(features, []float64{1 + offset, 1 - offset}, []float64{-1 - offset, -1 + offset})
+		labels = append(labels, "positive", "negative")
+	}
+	set, err := neuralnet.NewClassificationFeatureSet(features, labels, "negative", "positive")
+	if err != nil {
+		t.Fatal(err)
+	}
+	return set
+}
+
+func TestSearchHyperParametersGrid(t *testing.T) {
+	set := searchTestSet(t)
+	space := neuralnet.NewSearchSpace(neuralnet.NewHyperParametersBuilder().
+		AddLayers(neuralnet.ActivationFuncNameSigmoid, 1).
+		SetIterations(50).
+		SetSeed(1)).
+		LearningRates(0.001, 0.5).
+		HiddenLayers(neuralnet.ActivationFuncNameTanh, nil, []uint{4})
+
+	result, err := neuralnet.SearchHyperParameters(space, set, set, neuralnet.SearchOptions{
+		Method:      neuralnet.SearchGrid,
+		Parallelism: 2,
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(result.Leaderboard) != 4 {
+		t.Fatalf("Expected 4 candidates, but got %d", len(result.Leaderboard))
+	}
+	for i := 1; i < len(result.Leaderboard); i++ {
+		if result.Leaderboard[i].Loss < result.Leaderboard[i-1].Loss {
+			t.Errorf("Expected the leaderboard to be ranked by loss, but got\n%s", result)
+		}
+	}
+	best := result.Leaderboard[0]
+	if best.Description != "learning rate: 0.5, hidden layers: tanh [4]" &&
+		best.Description != "learning rate: 0.5, hidden layers: tanh []" {
+		t.Errorf("Expected a learning rate of 0.5 to win, but got\n%s", result)
+	}
+	if best.HyperParameters.String() != result.Best.String() {
+		t.Errorf("Expected the best hyperparameters to be the first of the leaderboard")
+	}
+}
+
+func TestSearchHyperParametersRandomWithCrossValidation(t *testing.T) {
+	set := searchTestSet(t)
+	space := neuralnet.NewSearchSpace(neuralnet.NewHyperParametersBuilder().
+		AddLayers(neuralnet.ActivationFuncNameSigmoid, 1).
+		SetIterations(50).
+		SetSeed(1)).
+		LearningRateRange(0.01, 1).
+		MiniBatchSizes(5, 10)
+
+	options := neuralnet.SearchOptions{Method: neuralnet.SearchRandom, Candidates: 3, Seed: 1, Folds: 2, Stratified: true}
+	result, err := neuralnet.SearchHyperParameters(space, set, nil, options)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(result.Leaderboard) != 3 {
+		t.Fatalf("Expected 3 candidates, but got %d", len(result.Leaderboard))
+	}
+	for _, candidate := range result.Leaderboard {
+		if len(candidate.CrossValidation.Folds) != 2 {
+			t.Errorf("Expected candidate %s to be cross-validated with 2 folds", candidate.Description)
+		}
+	}
+
+	options.Method = neuralnet.SearchGrid
+	if _, err := neuralnet.SearchHyperParameters(space, set, nil, options); err == nil {
+		t.Error("Expected an error searching a range with grid search")
+	}
+	options.Method = neuralnet.SearchRandom
+	if _, err := neuralnet.SearchHyperParameters(space, set, set, options); err == nil {
+		t.Error("Expected an error using both cross-validation and a validation set")
+	}
+}
+
+func TestSearchHyperParametersEmptyChoices(t *testing.T) {
+	set := searchTestSet(t)
+	space := neuralnet.NewSearchSpace(neuralnet.NewHyperParametersBuilder().
+		AddLayers(neuralnet.ActivationFuncNameSigmoid, 1).
+		SetIterations(5)).
+		LearningRates()
+
+	for _, method := range []neuralnet.SearchMethod{neuralnet.SearchGrid, neuralnet.SearchRandom} {
+		options := neuralnet.SearchOptions{Method: method, Candidates: 2}
+		if _, err := neuralnet.SearchHyperParameters(space, set, set, options); err == nil {
+			t.Errorf("Expected an error for %s search of a dimension without choices", method)
+		}
+	}
+}
@@ -0,0 +1,170 @@
+package neuralnet
+
+import (
+	"errors"
+	"fmt"
+	"hash/fnv"
+	"math"
+	"math/rand"
+	"path/filepath"
+	"sort"
+	"strings"
+)
+
+type SplitMethod string
+
+const (
+	// SplitRandom shuffles the images with the seed before splitting them
+	SplitRandom SplitMethod = "random"
+	// SplitStratified splits the images of each class (or combination of labels) separately, so every split has
+	// the same proportion of each class
+	SplitStratified SplitMethod = "stratified"
+	// SplitHash assigns each image to a split by hashing its path, so an image always lands in the same split even
+	// as images are added to or removed from the set
+	SplitHash SplitMethod = "hash"
+)
+
+// Split defines how to divide a set into training, validation and test sets. The ratios must add up to 1, and the
+// validation and test ratios can be 0 to skip those sets.
+type Split struct {
+	Train, Validation, Test float64
+	Method                  SplitMethod
+	// Seed seeds the shuffling of the random and stratified methods
+	Seed int64
+}
+
+func (s Split) validate() error {
+	if s.Train <= 0 || s.Validation < 0 || s.Test < 0 {
+		return errors.New("split train ratio must be greater than 0, and validation and test ratios cannot be negative")
+	}
+	if math.Abs(s.Train+s.Validation+s.Test-1) > 1e-9 {
+		return fmt.Errorf("split ratios must add up to 1, but add up to %.5g", s.Train+s.Validation+s.Test)
+	}
+	switch s.Method {
+	case SplitRandom, SplitStratified, SplitHash:
+		return nil
+	default:
+		return fmt.Errorf("unknown split method '%s'", s.Method)
+	}
+}
+
+// BuildSplit builds the images, divided into training, validation and test sets. Augmentation is only applied to
+// the training set, and normalization is fitted on the training set and reused for the others. The validation and
+// test sets are nil if their ratio is 0.
+func (builder ImageSetBuilder) BuildSplit(split Split) (train, validation, test *ImageSet, err error) {
+	if builder.err == nil {
+		builder.err = split.validate()
+	}
+	if builder.err == nil && split.Method == SplitStratified && builder.currentSet.kind == targetValues {
+		builder.err = errors.New("stratified split requires images with class labels")
+	}
+	if builder.err != nil {
+		builder.logError(builder.err)
+		return nil, nil, nil, builder.err
+	}
+
+	groups := builder.splitEntries(split)
+	build := func(entries []entry, train *ImageSet) (*ImageSet, error) {
+		if len(entries) == 0 {
+			return nil, errors.New("split has no images, use a larger ratio or more images")
+		}
+		b := builder
+		set := *builder.currentSet
+		set.entries = entries
+		// Copy the classes so all the sets have the same class indices, even if a split misses a class
+		set.classes = append([]string{}, builder.currentSet.classes...)
+		b.currentSet = &set
+		if train != nil {
+			b.augmentFlipHoriz = false
+			if builder.normalize && builder.normalizer == nil {
+				b = b.NormalizeWith(train.Normalizer())
+			}
+		}
+		return b.Build()
+	}
+
+	if train, err = build(groups[0], nil); err != nil {
+		return nil, nil, nil, err
+	}
+	if split.Validation > 0 {
+		if validation, err = build(groups[1], train); err != nil {
+			return nil, nil, nil, err
+		}
+	}
+	if split.Test > 0 {
+		if test, err = build(groups[2], train); err != nil {
+			return nil, nil, nil, err
+		}
+	}
+	return train, validation, test, nil
+}
+
+// splitEntries divides the entries of the set into the training, validation and test entries
+func (builder ImageSetBuilder) splitEntries(split Split) [3][]entry {
+	var groups [3][]entry
+	entries := builder.currentSet.entries
+
+	if split.Method == SplitHash {
+		for _, e := range entries {
+			position := hashPosition(builder.relativePath(e.pathToImage))
+			switch {
+			case position < split.Train:
+				groups[0] = append(groups[0], e)
+			case position < split.Train+split.Validation:
+				groups[1] = append(groups[1], e)
+			default:
+				groups[2] = append(groups[2], e)
+			}
+		}
+		return groups
+	}
+
+	// Random splits treat all the entries as a single stratum
+	strata := map[string][]entry{}
+	for _, e := range entries {
+		key := ""
+		if split.Method == SplitStratified {
+			key = e.label + "\x00" + strings.Join(e.labels, "\x00")
+		}
+		strata[key] = append(strata[key], e)
+	}
+	keys := make([]string, 0, len(strata))
+	for key := range strata {
+		keys = append(keys, key)
+	}
+	sort.Strings(keys)
+
+	rng := rand.New(rand.NewSource(split.Seed))
+	for _, key := range keys {
+		stratum := strata[key]
+		rng.Shuffle(len(stratum), func(i, j int) { stratum[i], stratum[j] = stratum[j], stratum[i] })
+		trainEnd := int(math.Round(float64(len(stratum)) * split.Train))
+		validationEnd := trainEnd + int(math.Round(float64(len(stratum))*split.Validation))
+		if validationEnd > len(stratum) {
+			validationEnd = len(stratum)
+		}
+		groups[0] = append(groups[0], stratum[:trainEnd]...)
+		groups[1] = append(groups[1], stratum[trainEnd:validationEnd]...)
+		groups[2] = append(groups[2], stratum[validationEnd:]...)
+	}
+	return groups
+}
+
+// relativePath returns the path of an image relative to the path prefix, so the hash doesn't depend on where the
+// images are stored
+func (builder ImageSetBuilder) relativePath(pathToImage string) string {
+	if builder.pathPrefix == "" {
+		return pathToImage
+	}
+	if relative, err := filepath.Rel(builder.pathPrefix, pathToImage); err == nil {
+		return relative
+	}
+	return pathToImage
+}
+
+// hashPosition maps a path to a position in [0, 1)
+func hashPosition(path string) float64 {
+	h := fnv.New64a()
+	h.Write([]byte(path))
+	return float64(h.Sum64()>>11) / float64(1<<53)
+}
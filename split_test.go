@@ -0,0 +1,146 @@
+package neuralnet_test
+
+import (
+	"image/color"
+	"testing"
+
+	"github.com/codehex/neuralnet"
+)
+
+func splitTestBuilder(t *testing.T) neuralnet.ImageSetBuilder {
+	t.Helper()
+	dir := t.TempDir()
+	writeTestImages(t, dir, "red", 10, color.RGBA{200, 20, 20, 255})
+	writeTestImages(t, dir, "blue", 10, color.RGBA{20, 20, 200, 255})
+	return neuralnet.NewImageSetBuilder().
+		WithPathPrefix(dir).
+		AddFolder("red", "red").
+		AddFolder("blue", "blue")
+}
+
+// predictedPaths returns the paths of the images of the set, as reported by the predictions of the model
+func predictedPaths(t *testing.T, set *neuralnet.ImageSet, model *neuralnet.TrainedModel) map[string]bool {
+	t.Helper()
+	predictions, err := model.Predict(set)
+	if err != nil {
+		t.Fatal(err)
+	}
+	result := map[string]bool{}
+	for _, p := range predictions {
+		result[p.PathToImage] = true
+	}
+	return result
+}
+
+func TestBuildSplitStratified(t *testing.T) {
+	train, validation, test, err := splitTestBuilder(t).
+		AugmentFlipHorizontal().
+		Normalize().
+		BuildSplit(neuralnet.Split{Train: 0.6, Validation: 0.2, Test: 0.2, Method: neuralnet.SplitStratified, Seed: 1})
+	if err != nil {
+		t.Fatal(err)
+	}
+	// Only the training set is augmented
+	if train.NumberOfExamples() != 24 || validation.NumberOfExamples() != 4 || test.NumberOfExamples() != 4 {
+		t.Fatalf("Expected 24/4/4 examples, but got %d/%d/%d",
+			train.NumberOfExamples(), validation.NumberOfExamples(), test.NumberOfExamples())
+	}
+	if validation.Normalizer() != train.Normalizer() || test.Normalizer() != train.Normalizer() {
+		t.Errorf("Expected the validation and test sets to be normalized with the training statistics")
+	}
+	for _, set := range []*neuralnet.ImageSet{validation, test} {
+		Y := set.Y()
+		positives := 0.0
+		for i := 0; i < int(set.NumberOfExamples()); i++ {
+			positives += Y.At(0, i)
+		}
+		if positives != 2 {
+			t.Errorf("Expected each class to have 2 examples, but got %v blue", positives)
+		}
+	}
+}
+
+func TestBuildSplitIsDeterministic(t *testing.T) {
+	builder := splitTestBuilder(t)
+	hyperParams, err := neuralnet.NewHyperParametersBuilder().
+		AddLayers(neuralnet.ActivationFuncNameSigmoid, 1).
+		SetIterations(1).
+		Build()
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	for _, method := range []neuralnet.SplitMethod{neuralnet.SplitRandom, neuralnet.SplitHash} {
+		split := neuralnet.Split{Train: 0.5, Test: 0.5, Method: method, Seed: 7}
+		train, validation, test, err := builder.BuildSplit(split)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if validation != nil {
+			t.Errorf("Expected no validation set for a ratio of 0")
+		}
+		model, err := hyperParams.TrainModel(train, nil)
+		if err != nil {
+			t.Fatal(err)
+		}
+		trainPaths, testPaths := predictedPaths(t, train, model), predictedPaths(t, test, model)
+		if len(trainPaths)+len(testPaths) != 20 {
+			t.Errorf("Expected every image in exactly one split, but got %d and %d", len(trainPaths), len(testPaths))
+		}
+		for p := range testPaths {
+			if trainPaths[p] {
+				t.Errorf("Expected %s to only be in one split", p)
+			}
+		}
+
+		again, _, _, err := builder.BuildSplit(split)
+		if err != nil {
+			t.Fatal(err)
+		}
+		againPaths := predictedPaths(t, again, model)
+		for p := range trainPaths {
+			if !againPaths[p] {
+				t.Errorf("Expected the %s split to be the same every time, but %s moved", method, p)
+			}
+		}
+	}
+}
+
+func TestBuildSplitInvalidRatios(t *testing.T) {
+	_, _, _, err := splitTestBuilder(t).BuildSplit(neuralnet.Split{Train: 0.8, Test: 0.1, Method: neuralnet.SplitRandom})
+	if err == nil {
+		t.Errorf("Expected an error for ratios that don't add up to 1")
+	}
+}
+
+func TestTrainModelWithoutValidationSplit(t *testing.T) {
+	train, validation, test, err := splitTestBuilder(t).
+		BuildSplit(neuralnet.Split{Train: 0.8, Test: 0.2, Method: neuralnet.SplitStratified, Seed: 1})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if validation != nil {
+		t.Fatalf("Expected no validation set for a ratio of 0")
+	}
+	hyperParams, err := neuralnet.NewHyperParametersBuilder().
+		AddLayers(neuralnet.ActivationFuncNameSigmoid, 1).
+		SetIterations(10).
+		Build()
+	if err != nil {
+		t.Fatal(err)
+	}
+	// The nil *ImageSet must be treated as no validation set, rather than as a set
+	model, err := hyperParams.TrainModel(train, validation)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, err := model.Evaluate(test); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := model.Evaluate(validation); err == nil {
+		t.Errorf("Expected an error evaluating a nil set")
+	}
+	if _, err := hyperParams.TrainModel((*neuralnet.ImageSet)(nil), nil); err == nil {
+		t.Errorf("Expected an error training on a nil set")
+	}
+}
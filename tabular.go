@@ -0,0 +1,448 @@
+package neuralnet
+
+import (
+	"encoding/csv"
+	"fmt"
+	"io"
+	"os"
+	"sort"
+	"strconv"
+	"strings"
+)
+
+type MissingValuePolicy string
+
+const (
+	// MissingValuesError fails to build the set if a value is missing
+	MissingValuesError MissingValuePolicy = "error"
+	// MissingValuesDropRow skips the rows with a missing value
+	MissingValuesDropRow MissingValuePolicy = "drop-row"
+	// MissingValuesImpute replaces missing values with the mean of a numeric column, or the most frequent value of a
+	// categorical column
+	MissingValuesImpute MissingValuePolicy = "impute"
+)
+
+// missingValues are the values of a CSV field that mark the value as missing
+var missingValues = map[string]bool{"": true, "NA": true, "N/A": true, "NaN": true, "null": true}
+
+// TabularEncoding describes how the columns of a CSV file were converted into features, so other files (e.g. a test
+// set, or data to make predictions for) can be encoded in exactly the same way
+type TabularEncoding struct {
+	Columns []TabularColumn
+	// Classes are the class labels of the target column, empty for a regression target
+	Classes []string
+	// Normalizer standardizes the numeric features, or is nil if they aren't standardized
+	Normalizer *Normalizer
+}
+
+// TabularColumn describes how a column is converted into features
+type TabularColumn struct {
+	Name string
+	// Categories holds the values of a categorical column, each converted into a feature set to 1 if the row has
+	// that value and 0 otherwise (one-hot encoding). It is empty for numeric columns, which are a single feature.
+	Categories []string
+	// Mean of a numeric column and Mode (most frequent value) of a categorical column, used to impute missing values
+	Mean float64
+	Mode string
+}
+
+// TabularSet is a Dataset read from a CSV file, with a row per example
+type TabularSet struct {
+	examples
+	encoding *TabularEncoding
+}
+
+// Encoding returns how the columns of the set were converted into features
+func (t *TabularSet) Encoding() *TabularEncoding {
+	return t.encoding
+}
+
+// FeatureNames returns the name of each feature, which is the column name for numeric columns and column=value for
+// each value of a categorical column
+func (t *TabularSet) FeatureNames() []string {
+	var names []string
+	for _, column := range t.encoding.Columns {
+		if len(column.Categories) == 0 {
+			names = append(names, column.Name)
+			continue
+		}
+		for _, category := range column.Categories {
+			names = append(names, column.Name+"="+category)
+		}
+	}
+	return names
+}
+
+type TabularSetBuilder struct {
+	reader      io.Reader
+	path        string
+	header      bool
+	delimiter   rune
+	columns     []string
+	target      string
+	regression  bool
+	categorical []string
+	missing     MissingValuePolicy
+	classes     []string
+	standardize bool
+	encoding    *TabularEncoding
+	err         error
+}
+
+func NewTabularSetBuilder() TabularSetBuilder {
+	return TabularSetBuilder{header: true, delimiter: ',', missing: MissingValuesError}
+}
+
+// FromFile reads the set from a CSV file
+func (builder TabularSetBuilder) FromFile(path string) TabularSetBuilder {
+	builder.path = path
+	builder.reader = nil
+	return builder
+}
+
+// FromReader reads the set from CSV data
+func (builder TabularSetBuilder) FromReader(r io.Reader) TabularSetBuilder {
+	builder.reader = r
+	builder.path = ""
+	return builder
+}
+
+// WithoutHeader reads the first row as data rather than the column names, columns are then named by their position
+// starting from 0
+func (builder TabularSetBuilder) WithoutHeader() TabularSetBuilder {
+	builder.header = false
+	return builder
+}
+
+// WithDelimiter sets the character separating the fields, a comma by default
+func (builder TabularSetBuilder) WithDelimiter(delimiter rune) TabularSetBuilder {
+	builder.delimiter = delimiter
+	return builder
+}
+
+// WithColumns selects the columns used as features, by default all the columns except the target are used
+func (builder TabularSetBuilder) WithColumns(names ...string) TabularSetBuilder {
+	builder.columns = append([]string{}, names...)
+	return builder
+}
+
+// WithTarget sets the column holding the class label of each row
+func (builder TabularSetBuilder) WithTarget(name string) TabularSetBuilder {
+	builder.target = name
+	builder.regression = false
+	return builder
+}
+
+// WithRegressionTarget sets the column holding the continuous target value of each row
+func (builder TabularSetBuilder) WithRegressionTarget(name string) TabularSetBuilder {
+	builder.target = name
+	builder.regression = true
+	return builder
+}
+
+// WithCategoricalColumns one-hot encodes the columns even if their values are numbers, e.g. codes. Columns with
+// values that aren't numbers are always categorical.
+func (builder TabularSetBuilder) WithCategoricalColumns(names ...string) TabularSetBuilder {
+	builder.categorical = append(append([]string{}, builder.categorical...), names...)
+	return builder
+}
+
+// WithMissingValues sets how empty, NA, N/A, NaN and null values are handled, by default they are an error.
+// Missing targets are only allowed when dropping rows.
+func (builder TabularSetBuilder) WithMissingValues(policy MissingValuePolicy) TabularSetBuilder {
+	if builder.err == nil && policy != MissingValuesError && policy != MissingValuesDropRow && policy != MissingValuesImpute {
+		builder.err = fmt.Errorf("unknown missing value policy '%s'", policy)
+	}
+	builder.missing = policy
+	return builder
+}
+
+// WithClasses fixes the order of the class labels of the target, otherwise they are ordered by first appearance
+func (builder TabularSetBuilder) WithClasses(labels ...string) TabularSetBuilder {
+	builder.classes = append([]string{}, labels...)
+	return builder
+}
+
+// Standardize scales the numeric features to zero mean and unit standard deviation, using the statistics of this set
+func (builder TabularSetBuilder) Standardize() TabularSetBuilder {
+	builder.standardize = true
+	return builder
+}
+
+// WithEncoding converts the columns into features like another set, typically the training set. This reuses its
+// columns, categories, imputed values, classes and standardization. Categories the encoding doesn't know are
+// encoded as all zeros.
+func (builder TabularSetBuilder) WithEncoding(encoding *TabularEncoding) TabularSetBuilder {
+	if builder.err == nil && encoding == nil {
+		builder.err = fmt.Errorf("encoding cannot be nil")
+	}
+	builder.encoding = encoding
+	return builder
+}
+
+func (builder TabularSetBuilder) Build() (*TabularSet, error) {
+	if builder.err != nil {
+		return nil, builder.err
+	}
+	if builder.target == "" {
+		return nil, fmt.Errorf("target column must be set")
+	}
+
+	names, rows, err := builder.readRecords()
+	if err != nil {
+		return nil, err
+	}
+	columnIndex := map[string]int{}
+	for index, name := range names {
+		columnIndex[name] = index
+	}
+	targetIndex, ok := columnIndex[builder.target]
+	if !ok {
+		return nil, fmt.Errorf("target column '%s' not found", builder.target)
+	}
+
+	encoding := builder.encoding
+	if encoding == nil {
+		encoding = &TabularEncoding{}
+		featureNames := builder.columns
+		if len(featureNames) == 0 {
+			for _, name := range names {
+				if name != builder.target {
+					featureNames = append(featureNames, name)
+				}
+			}
+		}
+		for _, name := range featureNames {
+			encoding.Columns = append(encoding.Columns, TabularColumn{Name: name})
+		}
+	}
+	if len(encoding.Columns) == 0 {
+		return nil, fmt.Errorf("no feature columns")
+	}
+	indices := make([]int, len(encoding.Columns))
+	for i, column := range encoding.Columns {
+		index, ok := columnIndex[column.Name]
+		if !ok {
+			return nil, fmt.Errorf("column '%s' not found", column.Name)
+		}
+		if index == targetIndex {
+			return nil, fmt.Errorf("target column '%s' cannot also be a feature", column.Name)
+		}
+		indices[i] = index
+	}
+
+	// Drop or reject rows with missing values
+	firstRow := 1
+	if builder.header {
+		firstRow = 2
+	}
+	// lines holds the row number of each kept row, for the errors encoding them
+	kept, lines := rows[:0:0], []int{}
+	for r, row := range rows {
+		missing := -1
+		for _, index := range append([]int{targetIndex}, indices...) {
+			if missingValues[row[index]] && (index == targetIndex || builder.missing != MissingValuesImpute) {
+				missing = index
+				break
+			}
+		}
+		if missing < 0 {
+			kept = append(kept, row)
+			lines = append(lines, r+firstRow)
+			continue
+		}
+		if builder.missing != MissingValuesDropRow {
+			return nil, fmt.Errorf("missing value in column '%s' of row %d", names[missing], r+firstRow)
+		}
+	}
+	rows = kept
+	if len(rows) == 0 {
+		return nil, fmt.Errorf("no rows to build the set from")
+	}
+
+	if builder.encoding == nil {
+		for i := range encoding.Columns {
+			builder.fitColumn(&encoding.Columns[i], rows, indices[i])
+		}
+	}
+
+	set := &TabularSet{encoding: encoding}
+	set.kind = classLabels
+	if builder.regression {
+		set.kind = targetValues
+	}
+	classes := builder.classes
+	if builder.encoding != nil {
+		classes = builder.encoding.Classes
+	}
+	for _, class := range classes {
+		set.addClass(class)
+	}
+
+	for r, row := range rows {
+		vector, err := encodeRow(encoding.Columns, row, indices)
+		if err != nil {
+			return nil, fmt.Errorf("row %d: %w", lines[r], err)
+		}
+		e := entry{featureVector: vector}
+		if builder.regression {
+			e.target, err = strconv.ParseFloat(strings.TrimSpace(row[targetIndex]), 64)
+			if err != nil {
+				return nil, fmt.Errorf("row %d: target '%s' is not a number", lines[r], row[targetIndex])
+			}
+		} else {
+			e.label = row[targetIndex]
+			set.addClass(e.label)
+		}
+		set.entries = append(set.entries, e)
+	}
+	set.featureCount = uint(len(set.entries[0].featureVector))
+
+	if builder.encoding == nil && builder.standardize {
+		encoding.Normalizer = fitTabularNormalizer(encoding.Columns, set.entries)
+	}
+	if encoding.Normalizer != nil {
+		if err := encoding.Normalizer.validate(set.featureCount); err != nil {
+			return nil, err
+		}
+		for i := range set.entries {
+			encoding.Normalizer.apply(set.entries[i].featureVector)
+		}
+	}
+	if builder.encoding == nil && !builder.regression {
+		encoding.Classes = set.classes
+	}
+
+	set.vectorise()
+	return set, nil
+}
+
+// readRecords reads the column names and rows of the CSV data
+func (builder TabularSetBuilder) readRecords() ([]string, [][]string, error) {
+	r := builder.reader
+	if builder.path != "" {
+		file, err := os.Open(builder.path)
+		if err != nil {
+			return nil, nil, fmt.Errorf("error opening file %s: %w", builder.path, err)
+		}
+		defer file.Close()
+		r = file
+	}
+	if r == nil {
+		return nil, nil, fmt.Errorf("no CSV file or reader to build the set from")
+	}
+
+	reader := csv.NewReader(r)
+	reader.Comma = builder.delimiter
+	reader.TrimLeadingSpace = true
+	records, err := reader.ReadAll()
+	if err != nil {
+		return nil, nil, fmt.Errorf("error reading CSV: %w", err)
+	}
+	if len(records) == 0 {
+		return nil, nil, fmt.Errorf("CSV has no rows")
+	}
+
+	if builder.header {
+		return records[0], records[1:], nil
+	}
+	names := make([]string, len(records[0]))
+	for i := range names {
+		names[i] = strconv.Itoa(i)
+	}
+	return names, records, nil
+}
+
+// fitColumn decides whether the column is numeric or categorical, and calculates the values used to impute it
+func (builder TabularSetBuilder) fitColumn(column *TabularColumn, rows [][]string, index int) {
+	categorical := false
+	for _, name := range builder.categorical {
+		categorical = categorical || name == column.Name
+	}
+
+	sum, count := 0.0, 0
+	counts := map[string]int{}
+	for _, row := range rows {
+		value := row[index]
+		if missingValues[value] {
+			continue
+		}
+		counts[value]++
+		if v, err := strconv.ParseFloat(strings.TrimSpace(value), 64); err == nil {
+			sum += v
+			count++
+		} else {
+			categorical = true
+		}
+	}
+
+	if !categorical {
+		if count > 0 {
+			column.Mean = sum / float64(count)
+		}
+		return
+	}
+	for category := range counts {
+		column.Categories = append(column.Categories, category)
+	}
+	sort.Strings(column.Categories)
+	for _, category := range column.Categories {
+		if counts[category] > counts[column.Mode] {
+			column.Mode = category
+		}
+	}
+}
+
+// encodeRow converts the fields of a row into a feature vector, imputing missing values
+func encodeRow(columns []TabularColumn, row []string, indices []int) ([]float64, error) {
+	var vector []float64
+	for i, column := range columns {
+		value := row[indices[i]]
+		if len(column.Categories) > 0 {
+			if missingValues[value] {
+				value = column.Mode
+			}
+			for _, category := range column.Categories {
+				if category == value {
+					vector = append(vector, 1)
+				} else {
+					vector = append(vector, 0)
+				}
+			}
+			continue
+		}
+
+		if missingValues[value] {
+			vector = append(vector, column.Mean)
+			continue
+		}
+		v, err := strconv.ParseFloat(strings.TrimSpace(value), 64)
+		if err != nil {
+			return nil, fmt.Errorf("value '%s' of numeric column '%s' is not a number", value, column.Name)
+		}
+		vector = append(vector, v)
+	}
+	return vector, nil
+}
+
+// fitTabularNormalizer standardizes the numeric features, leaving the one-hot encoded features as 0 or 1
+func fitTabularNormalizer(columns []TabularColumn, entries []entry) *Normalizer {
+	vectors := make([][]float64, len(entries))
+	for i := range entries {
+		vectors[i] = entries[i].featureVector
+	}
+	normalizer := fitNormalizer(vectors, false)
+	feature := 0
+	for _, column := range columns {
+		if len(column.Categories) > 0 {
+			for range column.Categories {
+				normalizer.Mean[feature] = 0
+				normalizer.StdDev[feature] = 1
+				feature++
+			}
+			continue
+		}
+		feature++
+	}
+	return normalizer
+}
@@ -0,0 +1,122 @@
+package neuralnet_test
+
+import (
+	"math"
+	"strings"
+	"testing"
+
+	"github.com/codehex/neuralnet"
+)
+
+const fruitCSV = `weight,colour,sweetness,fruit
+150,red,7,apple
+170,green,5,apple
+NA,red,8,apple
+120,yellow,9,banana
+115,yellow,NA,banana
+130,green,6,banana
+`
+
+func TestTabularSetBuilder(t *testing.T) {
+	set, err := neuralnet.NewTabularSetBuilder().
+		FromReader(strings.NewReader(fruitCSV)).
+		WithTarget("fruit").
+		WithMissingValues(neuralnet.MissingValuesImpute).
+		Standardize().
+		Build()
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	names := set.FeatureNames()
+	expected := []string{"weight", "colour=green", "colour=red", "colour=yellow", "sweetness"}
+	if strings.Join(names, ",") != strings.Join(expected, ",") {
+		t.Fatalf("Expected features %v, but got %v", expected, names)
+	}
+	if set.NumberOfExamples() != 6 || set.FeatureCount() != 5 {
+		t.Fatalf("Expected 6 examples with 5 features, but got %d with %d", set.NumberOfExamples(), set.FeatureCount())
+	}
+	if classes := set.Classes(); len(classes) != 2 || classes[0] != "apple" || classes[1] != "banana" {
+		t.Errorf("Expected classes [apple banana], but got %v", classes)
+	}
+
+	X := set.X()
+	// The numeric columns are standardized, the missing weight imputed with the mean so it is standardized to 0
+	if math.Abs(X.At(0, 2)) > 1e-9 {
+		t.Errorf("Expected the imputed weight to be standardized to 0, but got %v", X.At(0, 2))
+	}
+	// The one-hot encoded colours are left as 0 or 1
+	if X.At(1, 0) != 0 || X.At(2, 0) != 1 || X.At(3, 0) != 0 {
+		t.Errorf("Expected the first row to be one-hot encoded as red, but got %v %v %v", X.At(1, 0), X.At(2, 0), X.At(3, 0))
+	}
+	if Y := set.Y(); Y.At(0, 0) != 0 || Y.At(0, 3) != 1 {
+		t.Errorf("Expected apples to be class 0 and bananas class 1")
+	}
+
+	// Another file is encoded with the columns, categories and statistics of the first
+	test, err := neuralnet.NewTabularSetBuilder().
+		FromReader(strings.NewReader("fruit,sweetness,colour,weight\napple,7,purple,150\n")).
+		WithTarget("fruit").
+		WithEncoding(set.Encoding()).
+		Build()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if test.FeatureCount() != 5 || test.X().At(0, 0) != X.At(0, 0) {
+		t.Errorf("Expected the test set to be encoded like the training set")
+	}
+	if test.X().At(1, 0) != 0 || test.X().At(2, 0) != 0 || test.X().At(3, 0) != 0 {
+		t.Errorf("Expected an unknown colour to be encoded as all zeros")
+	}
+
+	hyperParams, err := neuralnet.NewHyperParametersBuilder().
+		AddLayers(neuralnet.ActivationFuncNameSigmoid, 1).
+		SetLearningRate(0.5).
+		SetIterations(200).
+		Build()
+	if err != nil {
+		t.Fatal(err)
+	}
+	model, err := hyperParams.TrainModel(set, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if eval, err := model.Evaluate(set); err != nil || eval.Accuracy < 1 {
+		t.Errorf("Expected the model to separate the fruits, but got %v (%v)", eval, err)
+	}
+}
+
+func TestTabularSetBuilderMissingValues(t *testing.T) {
+	if _, err := neuralnet.NewTabularSetBuilder().
+		FromReader(strings.NewReader(fruitCSV)).
+		WithTarget("fruit").
+		Build(); err == nil {
+		t.Errorf("Expected an error for missing values by default")
+	}
+
+	set, err := neuralnet.NewTabularSetBuilder().
+		FromReader(strings.NewReader(fruitCSV)).
+		WithRegressionTarget("sweetness").
+		WithColumns("weight", "colour").
+		WithMissingValues(neuralnet.MissingValuesDropRow).
+		Build()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if set.NumberOfExamples() != 4 || !set.Regression() {
+		t.Errorf("Expected 4 regression examples after dropping rows, but got %d", set.NumberOfExamples())
+	}
+	if set.Y().At(0, 3) != 6 {
+		t.Errorf("Expected the targets to be the sweetness, but got %v", set.Y().At(0, 3))
+	}
+
+	// Errors give the row number in the CSV data, counting the dropped rows
+	_, err = neuralnet.NewTabularSetBuilder().
+		FromReader(strings.NewReader("weight,sweetness\nNA,7\n170,5\n120,sweet\n")).
+		WithRegressionTarget("sweetness").
+		WithMissingValues(neuralnet.MissingValuesDropRow).
+		Build()
+	if err == nil || !strings.Contains(err.Error(), "row 4") {
+		t.Errorf("Expected an error for the target of row 4, but got %v", err)
+	}
+}
@@ -0,0 +1,272 @@
+package neuralnet
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"math/rand"
+)
+
+// trainer holds the state of a model while it is being trained
+type trainer struct {
+	h          HyperParameters
+	nodes      []uint
+	training   Dataset
+	validation Dataset
+	batches    []batch
+	opt        optimizer
+	model      *TrainedModel
+	history    *TrainingHistory
+	stopping   earlyStopping
+	// seed seeds the generator used to initialize the weights, and the generators of every iteration, which are
+	// used for shuffling and dropout
+	seed int64
+	rng  *rand.Rand
+	// step is the number of mini-batches processed and iteration the number of complete iterations
+	step      uint
+	iteration uint
+}
+
+func (h HyperParameters) newTrainer(trainingDataSet, validationDataSet Dataset) (*trainer, error) {
+	trainingDataSet, validationDataSet = datasetOrNil(trainingDataSet), datasetOrNil(validationDataSet)
+	if trainingDataSet == nil {
+		return nil, errors.New("training set cannot be nil")
+	}
+	nodes := h.generateNodes(trainingDataSet.FeatureCount())
+	if rows, _ := trainingDataSet.Y().Dims(); uint(rows) != nodes[len(nodes)-1] {
+		return nil, fmt.Errorf("training set with %d classes cannot be used with %d neuron(s) in the last layer",
+			len(trainingDataSet.Classes()), nodes[len(nodes)-1])
+	}
+	if rows, _ := trainingDataSet.Y().Dims(); !trainingDataSet.Regression() {
+		// A single row of labels separates 2 classes, otherwise there is a row per class
+		expected := rows
+		if rows == 1 && !trainingDataSet.MultiLabel() {
+			expected = 2
+		}
+		if classes := len(trainingDataSet.Classes()); classes != expected {
+			return nil, fmt.Errorf("training set has %d classes, but its labels require %d", classes, expected)
+		}
+	}
+	if trainingDataSet.Regression() && !h.loss.regression() {
+		return nil, fmt.Errorf("%s loss cannot be used with continuous targets, use mean squared error, mean absolute error or huber", h.loss.Name)
+	}
+	output := h.outputLayer()
+	if trainingDataSet.MultiLabel() && output.actFuncLabel != ActivationFuncNameSigmoid {
+		return nil, errors.New("multi-label training set requires a last layer with sigmoid activation function")
+	}
+	if !trainingDataSet.MultiLabel() && output.actFuncLabel == ActivationFuncNameSigmoid && output.neurons != 1 {
+		return nil, errors.New("last layer with several sigmoid neurons requires a multi-label training set")
+	}
+	if validationDataSet != nil && datasetKind(validationDataSet) != datasetKind(trainingDataSet) {
+		return nil, fmt.Errorf("validation set has %s, but the training set has %s",
+			datasetKind(validationDataSet), datasetKind(trainingDataSet))
+	}
+	if validationDataSet != nil && validationDataSet.FeatureCount() != trainingDataSet.FeatureCount() {
+		return nil, fmt.Errorf("validation set has %d features per example, but the training set has %d",
+			validationDataSet.FeatureCount(), trainingDataSet.FeatureCount())
+	}
+
+	var preprocessing Preprocessing
+	if set, ok := trainingDataSet.(preprocessedSet); ok {
+		preprocessing = set.Preprocessing()
+	}
+	if h.earlyStopping.patience > 0 && validationDataSet == nil {
+		return nil, errors.New("early stopping requires a validation set")
+	}
+
+	seed := h.newSeed()
+	rng := rand.New(rand.NewSource(seed))
+	return &trainer{
+		h:          h,
+		nodes:      nodes,
+		training:   trainingDataSet,
+		validation: validationDataSet,
+		batches:    h.partitionSamples(h.miniBatchSize, nodes, trainingDataSet),
+		opt:        newOptimizer(h.optimizer, nodes),
+		model: &TrainedModel{
+			hyper:         h,
+			params:        h.initParameters(nodes, rng),
+			classes:       trainingDataSet.Classes(),
+			kind:          datasetKind(trainingDataSet),
+			preprocessing: preprocessing,
+		},
+		history:  &TrainingHistory{},
+		stopping: earlyStopping{config: h.earlyStopping},
+		seed:     seed,
+	}, nil
+}
+
+// run trains the model until all the iterations have run, early stopping ends the training or the time budget
+// runs out. If the context is cancelled, the partially trained model is returned with the context's error.
+func (t *trainer) run(ctx context.Context) (*TrainedModel, error) {
+	budgetCtx := ctx
+	if t.h.timeBudget > 0 {
+		var cancel context.CancelFunc
+		budgetCtx, cancel = context.WithTimeout(ctx, t.h.timeBudget)
+		defer cancel()
+	}
+
+	t.start()
+	var ctxErr error
+	// checkpoint holds the state after the last complete iteration, as an interrupted iteration has to run again
+	// from its start when the training is resumed
+	var checkpoint checkpointFile
+	if t.h.checkpoint.dir != "" {
+		checkpoint = t.checkpoint()
+	}
+	for t.iteration < t.h.iterations {
+		stop, err := t.runIteration(budgetCtx)
+		if err != nil && budgetCtx.Err() == nil {
+			return nil, err
+		}
+		if err != nil {
+			// Running out of the time budget ends the training normally, only the caller cancelling is an error
+			ctxErr = ctx.Err()
+			t.history.BudgetExhausted = ctxErr == nil
+			if t.h.checkpoint.dir != "" {
+				if err := t.writeCheckpoint(checkpoint); err != nil {
+					return t.finish(), errors.Join(ctxErr, err)
+				}
+			}
+			break
+		}
+		if t.h.checkpoint.dir != "" {
+			checkpoint = t.checkpoint()
+			if t.iteration%t.h.checkpoint.every == 0 {
+				if err := t.writeCheckpoint(checkpoint); err != nil {
+					return nil, err
+				}
+			}
+		}
+		if stop {
+			t.history.StoppedEarly = true
+			break
+		}
+	}
+	return t.finish(), ctxErr
+}
+
+// start notifies the callbacks that the training is starting
+func (t *trainer) start() {
+	for _, callback := range t.h.callbacks {
+		callback.OnTrainStart(TrainStartEvent{Iterations: t.h.iterations, BatchesPerEpoch: len(t.batches), Model: t.model})
+	}
+}
+
+// trainUntil trains the model until it has run the given number of iterations (or all of them, if fewer), unless
+// early stopping ends the training first
+func (t *trainer) trainUntil(ctx context.Context, iterations uint) error {
+	if iterations > t.h.iterations {
+		iterations = t.h.iterations
+	}
+	for t.iteration < iterations && !t.history.StoppedEarly {
+		stop, err := t.runIteration(ctx)
+		if err != nil {
+			return err
+		}
+		t.history.StoppedEarly = stop
+	}
+	return nil
+}
+
+// validationLoss returns the loss of the validation set with the parameters the model will finish with
+func (t *trainer) validationLoss() float64 {
+	if t.stopping.best != nil {
+		return t.stopping.bestLoss
+	}
+	return t.history.Epochs[len(t.history.Epochs)-1].Validation.Loss
+}
+
+// finish completes the model once training has ended, restoring the best parameters if early stopping is used
+func (t *trainer) finish() *TrainedModel {
+	if t.stopping.best != nil {
+		t.model.params = t.stopping.best
+		t.history.BestIteration = t.stopping.bestIteration
+	}
+	t.model.history = t.history
+	for _, callback := range t.h.callbacks {
+		callback.OnTrainEnd(TrainEndEvent{History: t.history, Model: t.model})
+	}
+	return t.model
+}
+
+// runIteration trains the model on every mini-batch once, returning true if early stopping should end the training.
+// The context is checked before every mini-batch, returning its error if it is done.
+func (t *trainer) runIteration(ctx context.Context) (bool, error) {
+	h := t.h
+	params := t.model.params
+	m := t.training.NumberOfExamples()
+	L := len(t.nodes) - 1
+	metrics := EpochMetrics{Iteration: t.iteration}
+	// Every iteration has its own generator, so a resumed training draws the same numbers as an uninterrupted one
+	t.rng = rand.New(rand.NewSource(iterationSeed(t.seed, t.iteration)))
+	if len(t.batches) > 1 && !h.noShuffle {
+		shuffleBatches(t.batches, t.training, t.rng)
+	}
+
+	for batchIndex, batch := range t.batches {
+		if err := ctx.Err(); err != nil {
+			return false, err
+		}
+		learningRate := h.learningRateAt(t.iteration, t.step, uint(len(t.batches)))
+		t.step++
+		// Forward propagation
+		for i := 1; i < len(t.nodes); i++ {
+			h.forwardPropagation(batch.X, batch.cache, params, i, t.rng)
+		}
+
+		// Set up the cache for the last layer
+		// For both sigmoid with binary cross-entropy and softmax with categorical cross-entropy
+		// the derivative of the cost with respect to ZL simplifies to dZL = AL - Y, other losses
+		// provide the derivative with respect to AL
+		if h.loss.fused(h.outputLayer().actFuncLabel) {
+			batch.cache[L].DZ.MatrixElemOp(batch.cache[L].A, batch.Y, func(a, y float64) float64 {
+				return a - y
+			})
+		} else {
+			batch.cache[L].DA.MatrixElemOp(batch.cache[L].A, batch.Y, h.loss.gradient)
+		}
+
+		// Weight the cost of each batch by its size, as the last batch may be smaller
+		loss := h.costFunction(batch.cache[L].A, batch.Y, params.W[L])
+		_, batchSize := batch.Y.Dims()
+		metrics.Loss += loss * float64(batchSize) / float64(m)
+		metrics.LearningRate = learningRate
+
+		// Backward propagation and update parameters
+		for i := L; i > 0; i-- {
+			h.backwardPropagation(batch.X, batch.cache, params, i, m)
+			t.opt.update(params, batch.cache, i, learningRate, t.step)
+		}
+
+		for _, callback := range h.callbacks {
+			callback.OnBatchEnd(BatchEndEvent{
+				Iteration: t.iteration, Batch: batchIndex, Loss: loss, LearningRate: learningRate, Model: t.model,
+			})
+		}
+	}
+
+	stop := false
+	if t.validation != nil {
+		eval, err := t.model.Evaluate(t.validation)
+		if err != nil {
+			return false, fmt.Errorf("error evaluating validation set: %w", err)
+		}
+		metrics.Validation = &eval
+		stop = t.stopping.check(t.iteration, eval, params)
+	}
+
+	t.history.Epochs = append(t.history.Epochs, metrics)
+	for _, callback := range h.callbacks {
+		callback.OnEpochEnd(EpochEndEvent{Iteration: t.iteration, Metrics: metrics, Model: t.model})
+	}
+	t.iteration++
+	return stop, nil
+}
+
+// iterationSeed derives the seed of the generator of an iteration from the seed of the training
+func iterationSeed(seed int64, iteration uint) int64 {
+	// Multiplying by the golden ratio spreads the seeds of consecutive iterations, so the iterations of runs with
+	// consecutive seeds don't share generators
+	return int64(uint64(seed) + uint64(iteration+1)*0x9e3779b97f4a7c15)
+}
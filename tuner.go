@@ -0,0 +1,226 @@
+package neuralnet
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"sort"
+)
+
+// TunerOptions configures SuccessiveHalving and Hyperband
+type TunerOptions struct {
+	// Candidates is the number of candidates successive halving samples from the search space, or 0 to try every
+	// combination of its choices. Hyperband picks the number of candidates of each bracket itself.
+	Candidates uint
+	// MinIterations is the number of iterations the candidates are trained for before the weakest are first
+	// discarded
+	MinIterations uint
+	// Eta is the factor the candidates are divided by, and their iterations multiplied by, every round (3 if 0)
+	Eta uint
+	// Seed seeds the sampling of the candidates
+	Seed int64
+	// Parallelism is the number of candidates trained at the same time, 0 or 1 trains them one after the other
+	Parallelism uint
+}
+
+func (o TunerOptions) validate() (TunerOptions, error) {
+	if o.Eta == 0 {
+		o.Eta = 3
+	}
+	if o.Eta < 2 {
+		return o, errors.New("tuner eta must be at least 2")
+	}
+	if o.MinIterations == 0 {
+		return o, errors.New("tuner min iterations must be greater than 0")
+	}
+	return o, nil
+}
+
+// tunedCandidate is a candidate of successive halving, with the number of iterations of the last round it was
+// trained in
+type tunedCandidate struct {
+	SearchCandidate
+	budget uint
+}
+
+// SuccessiveHalving tunes the candidates of the search space, see SuccessiveHalvingContext
+func SuccessiveHalving(space SearchSpace, trainingDataSet, validationDataSet Dataset, options TunerOptions) (SearchResult, error) {
+	return SuccessiveHalvingContext(context.Background(), space, trainingDataSet, validationDataSet, options)
+}
+
+// SuccessiveHalvingContext trains the candidates of the search space for MinIterations, discards all but the 1/Eta with the
+// lowest validation loss, and continues training the survivors from their current weights for Eta times as many
+// iterations, until the iterations of the base builder are reached. The leaderboard ranks the candidates that
+// survived longest first, then by their validation loss. Checkpointing and time budgets are disabled for the
+// candidates, and callbacks are called concurrently if candidates are trained in parallel.
+func SuccessiveHalvingContext(ctx context.Context, space SearchSpace, trainingDataSet, validationDataSet Dataset, options TunerOptions) (SearchResult, error) {
+	options, err := options.validate()
+	if err != nil {
+		return SearchResult{}, err
+	}
+	searchOptions := SearchOptions{Method: SearchGrid}
+	if options.Candidates > 0 {
+		searchOptions = SearchOptions{Method: SearchRandom, Candidates: options.Candidates, Seed: options.Seed}
+	}
+	candidates, err := space.candidates(searchOptions)
+	if err != nil {
+		return SearchResult{}, err
+	}
+	tuned, err := successiveHalving(ctx, candidates, trainingDataSet, validationDataSet, options.MinIterations, options)
+	if err != nil {
+		return SearchResult{}, err
+	}
+	return tuningResult(tuned)
+}
+
+// Hyperband tunes the candidates of the search space, see HyperbandContext
+func Hyperband(space SearchSpace, trainingDataSet, validationDataSet Dataset, options TunerOptions) (SearchResult, error) {
+	return HyperbandContext(context.Background(), space, trainingDataSet, validationDataSet, options)
+}
+
+// HyperbandContext runs successive halving in brackets that trade the number of candidates for the iterations they start
+// with, from many candidates starting with MinIterations to a few trained for all the iterations of the base builder,
+// so it doesn't matter as much how soon good candidates can be told apart. Every bracket samples its candidates from
+// the search space. The leaderboard ranks the candidates of all the brackets like SuccessiveHalvingContext.
+func HyperbandContext(ctx context.Context, space SearchSpace, trainingDataSet, validationDataSet Dataset, options TunerOptions) (SearchResult, error) {
+	options, err := options.validate()
+	if err != nil {
+		return SearchResult{}, err
+	}
+	maxIterations := space.base.params.iterations
+	if options.MinIterations > maxIterations {
+		return SearchResult{}, fmt.Errorf("tuner min iterations cannot be greater than the %d iterations", maxIterations)
+	}
+	brackets := 0
+	for iterations := options.MinIterations * options.Eta; iterations <= maxIterations; iterations *= options.Eta {
+		brackets++
+	}
+
+	var all []tunedCandidate
+	for s := brackets; s >= 0; s-- {
+		scale := uint(1)
+		for i := 0; i < s; i++ {
+			scale *= options.Eta
+		}
+		// Sample enough candidates so that every bracket uses about the same number of iterations
+		n := (uint(brackets+1)*scale + uint(s)) / uint(s+1)
+		candidates, err := space.candidates(SearchOptions{Method: SearchRandom, Candidates: n, Seed: options.Seed + int64(s)})
+		if err != nil {
+			return SearchResult{}, err
+		}
+		tuned, err := successiveHalving(ctx, candidates, trainingDataSet, validationDataSet, maxIterations/scale, options)
+		if err != nil {
+			return SearchResult{}, err
+		}
+		all = append(all, tuned...)
+	}
+	sort.SliceStable(all, func(i, j int) bool {
+		if all[i].budget != all[j].budget {
+			return all[i].budget > all[j].budget
+		}
+		return rankingLoss(all[i].Loss) < rankingLoss(all[j].Loss)
+	})
+	return tuningResult(all)
+}
+
+// successiveHalving trains the candidates for the given number of iterations, and keeps training the best 1/eta of
+// them for eta times as many iterations until all their iterations have run. It returns the candidates ranked by the
+// last round they were trained in, then by their validation loss.
+func successiveHalving(ctx context.Context, candidates []SearchCandidate, trainingDataSet, validationDataSet Dataset, iterations uint, options TunerOptions) ([]tunedCandidate, error) {
+	validationDataSet = datasetOrNil(validationDataSet)
+	if validationDataSet == nil {
+		return nil, errors.New("tuning requires a validation set")
+	}
+	trainers := make([]*trainer, len(candidates))
+	alive := make([]int, len(candidates))
+	maxIterations := uint(0)
+	for i, candidate := range candidates {
+		candidate.HyperParameters.timeBudget = 0
+		t, err := candidate.HyperParameters.newTrainer(trainingDataSet, validationDataSet)
+		if err != nil {
+			return nil, fmt.Errorf("candidate %s: %w", candidate.Description, err)
+		}
+		t.start()
+		trainers[i] = t
+		alive[i] = i
+		if t.h.iterations > maxIterations {
+			maxIterations = t.h.iterations
+		}
+	}
+
+	var discarded []tunedCandidate
+	for {
+		err := runConcurrently(len(alive), options.Parallelism, func(i int) error {
+			if err := trainers[alive[i]].trainUntil(ctx, iterations); err != nil {
+				return fmt.Errorf("candidate %s: %w", candidates[alive[i]].Description, err)
+			}
+			return nil
+		})
+		if err != nil {
+			return nil, err
+		}
+		sort.SliceStable(alive, func(i, j int) bool {
+			return rankingLoss(trainers[alive[i]].validationLoss()) < rankingLoss(trainers[alive[j]].validationLoss())
+		})
+
+		last := iterations >= maxIterations
+		keep := len(alive)
+		if !last {
+			keep = len(alive) / int(options.Eta)
+			if keep == 0 {
+				keep = 1
+			}
+		}
+		// Discarded candidates are ranked after the candidates that survive this round, so prepend them
+		var round []tunedCandidate
+		for _, i := range alive[keep:] {
+			candidate, err := finishCandidate(candidates[i], trainers[i], validationDataSet, iterations)
+			if err != nil {
+				return nil, err
+			}
+			// Release the mini-batches of the trainer
+			trainers[i] = nil
+			round = append(round, candidate)
+		}
+		discarded = append(round, discarded...)
+		alive = alive[:keep]
+		if last {
+			break
+		}
+		iterations *= options.Eta
+		if iterations > maxIterations {
+			iterations = maxIterations
+		}
+	}
+
+	ranked := make([]tunedCandidate, 0, len(candidates))
+	for _, i := range alive {
+		candidate, err := finishCandidate(candidates[i], trainers[i], validationDataSet, iterations)
+		if err != nil {
+			return nil, err
+		}
+		ranked = append(ranked, candidate)
+	}
+	return append(ranked, discarded...), nil
+}
+
+// finishCandidate completes the training of a candidate and scores it
+func finishCandidate(candidate SearchCandidate, t *trainer, validationDataSet Dataset, budget uint) (tunedCandidate, error) {
+	if err := candidate.evaluate(t.finish(), validationDataSet); err != nil {
+		return tunedCandidate{}, err
+	}
+	return tunedCandidate{SearchCandidate: candidate, budget: budget}, nil
+}
+
+// tuningResult returns the leaderboard of the tuned candidates, with the first as the best
+func tuningResult(tuned []tunedCandidate) (SearchResult, error) {
+	if len(tuned) == 0 {
+		return SearchResult{}, errors.New("search space has no candidates")
+	}
+	result := SearchResult{Leaderboard: make([]SearchCandidate, len(tuned))}
+	for i, candidate := range tuned {
+		result.Leaderboard[i] = candidate.SearchCandidate
+	}
+	result.Best = result.Leaderboard[0].HyperParameters
+	return result, nil
+}
@@ -0,0 +1,86 @@
+package neuralnet_test
+
+import (
+	"testing"
+
+	"github.com/codehex/neuralnet"
+)
+
+func TestSuccessiveHalving(t *testing.T) {
+	set := searchTestSet(t)
+	space := neuralnet.NewSearchSpace(neuralnet.NewHyperParametersBuilder().
+		AddLayers(neuralnet.ActivationFuncNameSigmoid, 1).
+		SetIterations(20).
+		SetSeed(1)).
+		LearningRates(0.0001, 0.001, 0.5, 1)
+
+	result, err := neuralnet.SuccessiveHalving(space, set, set, neuralnet.TunerOptions{
+		MinIterations: 5,
+		Eta:           2,
+		Parallelism:   2,
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	// 4 candidates are trained for 5 iterations, 2 of them for 10 and the best to the end
+	var iterations []uint
+	for _, candidate := range result.Leaderboard {
+		iterations = append(iterations, candidate.Iterations)
+		if candidate.Model == nil {
+			t.Errorf("Expected candidate %s to have a model", candidate.Description)
+		}
+	}
+	if len(iterations) != 4 || iterations[0] != 20 || iterations[1] != 10 || iterations[2] != 5 || iterations[3] != 5 {
+		t.Fatalf("Expected the candidates to be trained for 20, 10, 5 and 5 iterations, but got %v", iterations)
+	}
+	if best := result.Leaderboard[0].Description; best != "learning rate: 0.5" && best != "learning rate: 1" {
+		t.Errorf("Expected a high learning rate to win, but got\n%s", result)
+	}
+	if eval := result.Leaderboard[0].Evaluation; eval.Accuracy != 1 {
+		t.Errorf("Expected the best candidate to classify the validation set perfectly, but got %s", eval)
+	}
+
+	if _, err := neuralnet.SuccessiveHalving(space, set, nil, neuralnet.TunerOptions{MinIterations: 5}); err == nil {
+		t.Error("Expected an error tuning without a validation set")
+	}
+	if _, err := neuralnet.SuccessiveHalving(space, set, set, neuralnet.TunerOptions{}); err == nil {
+		t.Error("Expected an error tuning with 0 min iterations")
+	}
+}
+
+func TestHyperband(t *testing.T) {
+	set := searchTestSet(t)
+	space := neuralnet.NewSearchSpace(neuralnet.NewHyperParametersBuilder().
+		AddLayers(neuralnet.ActivationFuncNameSigmoid, 1).
+		SetIterations(45).
+		SetSeed(1)).
+		LearningRateRange(0.0001, 1)
+
+	result, err := neuralnet.Hyperband(space, set, set, neuralnet.TunerOptions{MinIterations: 5, Seed: 1, Parallelism: 4})
+	if err != nil {
+		t.Fatal(err)
+	}
+	// Brackets of 9 candidates starting with 5 iterations, 5 starting with 15 and 3 trained for all 45
+	if len(result.Leaderboard) != 17 {
+		t.Fatalf("Expected 17 candidates, but got %d", len(result.Leaderboard))
+	}
+	if result.Leaderboard[0].Iterations != 45 {
+		t.Errorf("Expected the best candidate to be trained for all 45 iterations, but got %d", result.Leaderboard[0].Iterations)
+	}
+}
+
+func TestTunersEmptyChoices(t *testing.T) {
+	set := searchTestSet(t)
+	space := neuralnet.NewSearchSpace(neuralnet.NewHyperParametersBuilder().
+		AddLayers(neuralnet.ActivationFuncNameSigmoid, 1).
+		SetIterations(20)).
+		HiddenLayers(neuralnet.ActivationFuncNameTanh)
+
+	options := neuralnet.TunerOptions{MinIterations: 5, Eta: 2}
+	if _, err := neuralnet.SuccessiveHalving(space, set, set, options); err == nil {
+		t.Error("Expected an error tuning a dimension without choices with successive halving")
+	}
+	if _, err := neuralnet.Hyperband(space, set, set, options); err == nil {
+		t.Error("Expected an error tuning a dimension without choices with Hyperband")
+	}
+}